@@ -0,0 +1,104 @@
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "time"
+
+  "github.com/spf13/cobra"
+)
+
+// saveSpec returns the spec written to package.json for a requested spec that
+// resolved to version. Ranges and exact versions are kept as typed; tags
+// (including the implicit latest) are saved as prefix+version.
+func saveSpec(spec, version, prefix string) string {
+  if isSourceSpec(spec) { return spec }
+  if real, rng, ok := parseAlias(spec); ok {
+    return aliasPrefix + keyOf(real, saveSpec(rng, realVersion(version), prefix))
+  }
+  if spec != "" && spec != "latest" {
+    if validRange(spec) { return spec }
+  }
+  return prefix + version
+}
+
+// addToManifest records name in section, moving it out of the other
+// dependency sections. --save-peer also keeps the package as a devDependency
+// so it is installed for local development.
+func addToManifest(pj *packageJSONFile, section, name, spec string) error {
+  targets := []string{section}
+  if section == sectionPeer { targets = append(targets, sectionDev) }
+  for _, other := range []string{sectionProd, sectionDev, sectionOptional} {
+    keep := false
+    for _, t := range targets { if t == other { keep = true } }
+    if keep { continue }
+    if _, err := pj.removeDependency(other, name); err != nil { return err }
+  }
+  for _, t := range targets {
+    if err := pj.setDependency(t, name, spec); err != nil { return err }
+  }
+  return nil
+}
+
+var addCmd = &cobra.Command{
+  Use:   "add <package>[@version|@range] [...]",
+  Short: "Add packages to package.json and install",
+  Args:  cobra.MinimumNArgs(1),
+  Run: func(cmd *cobra.Command, args []string) {
+    projectDir, cfg := installSetup(cmd)
+    // the saved specs must be installable under the release cutoff
+    if _, err := applyCutoff(cmd, cfg); err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    section := sectionProd
+    if dev, _ := cmd.Flags().GetBool("save-dev"); dev { section = sectionDev }
+    if opt, _ := cmd.Flags().GetBool("save-optional"); opt { section = sectionOptional }
+    if peer, _ := cmd.Flags().GetBool("save-peer"); peer { section = sectionPeer }
+    // flags > wlim.json > default "^"
+    prefix := "^"
+    if cfg.SavePrefix != "" { prefix = cfg.SavePrefix }
+    if cfg.SaveExact { prefix = "" }
+    if cmd.Flags().Changed("save-prefix") { prefix, _ = cmd.Flags().GetString("save-prefix") }
+    if exact, _ := cmd.Flags().GetBool("save-exact"); exact { prefix = "" }
+
+    pj, err := loadPackageJSONFile(projectDir)
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+    defer cancel()
+    cache := make(map[string]*RootDoc)
+    for _, arg := range args {
+      name, spec := splitPackageArg(arg)
+      v, md, err := resolveDep(ctx, name, absLocalSpec(spec, projectDir), cache)
+      if err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+      }
+      if name == "" { name = md.Name }
+      if err := addToManifest(pj, section, name, saveSpec(spec, v, prefix)); err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+      }
+    }
+    if err := pj.save(); err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    // install the updated package.json; the changed specs force a re-resolve
+    runInstall(cmd, nil)
+  },
+}
+
+func init() {
+  addInstallFlags(addCmd)
+  addCmd.Flags().BoolP("save-dev", "D", false, "Save to devDependencies")
+  addCmd.Flags().BoolP("save-optional", "O", false, "Save to optionalDependencies")
+  addCmd.Flags().Bool("save-peer", false, "Save to peerDependencies (and devDependencies)")
+  addCmd.Flags().BoolP("save-exact", "E", false, "Save the exact version instead of a range")
+  addCmd.Flags().String("save-prefix", "^", "Range prefix for saved versions: ^, ~ or empty")
+  rootCmd.AddCommand(addCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+  "encoding/json"
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+)
+
+func TestSaveSpec(t *testing.T) {
+  cases := []struct{ spec, version, prefix, want string }{
+    {"latest", "1.2.3", "^", "^1.2.3"},
+    {"next", "2.0.0-rc.1", "~", "~2.0.0-rc.1"},
+    {"latest", "1.2.3", "", "1.2.3"},
+    {"^1.0.0", "1.2.3", "~", "^1.0.0"},
+    {"1.0.0", "1.0.0", "^", "1.0.0"},
+  }
+  for _, c := range cases {
+    if got := saveSpec(c.spec, c.version, c.prefix); got != c.want {
+      t.Errorf("saveSpec(%q, %q, %q) = %q, want %q", c.spec, c.version, c.prefix, got, c.want)
+    }
+  }
+}
+
+func TestAddCommandWritesManifestAndInstalls(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0"},
+    testPkg{Name: "a", Version: "1.4.0"},
+    testPkg{Name: "b", Version: "3.1.0"},
+  )
+  proj := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte("{\n  \"name\": \"app\",\n  \"devDependencies\": {\n    \"a\": \"1.0.0\"\n  }\n}\n"), 0o644)
+
+  runCLI(t, "add", "a", "b", "--save-prefix", "~", "--dir", proj)
+
+  b, _ := os.ReadFile(filepath.Join(proj, "package.json"))
+  var m struct {
+    Dependencies    map[string]string `json:"dependencies"`
+    DevDependencies map[string]string `json:"devDependencies"`
+  }
+  if err := json.Unmarshal(b, &m); err != nil { t.Fatalf("package.json: %v", err) }
+  if m.Dependencies["a"] != "~1.4.0" || m.Dependencies["b"] != "~3.1.0" { t.Fatalf("unexpected dependencies: %s", b) }
+  if m.DevDependencies != nil { t.Fatalf("a should have moved out of devDependencies: %s", b) }
+  if _, err := os.Lstat(filepath.Join(proj, "node_modules", "b")); err != nil { t.Fatalf("b not linked: %v", err) }
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatalf("lockfile: %v", err) }
+  if lf.RootSpecs["a"].Spec != "~1.4.0" { t.Fatalf("unexpected root specs: %+v", lf.RootSpecs) }
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "strings"
+)
+
+// aliasPrefix starts npm alias specs ("string-width-cjs": "npm:string-width@^4").
+// An aliased package is linked under the alias name and fetched and stored
+// under its real name. Its node keeps the alias as Name and records the real
+// package in Version as npm:<name>@<version>, so refs and lockfile keys
+// (alias@npm:name@version) carry both.
+const aliasPrefix = "npm:"
+
+// parseAlias splits an alias spec npm:<name>[@<range>]; the range defaults
+// to latest.
+func parseAlias(spec string) (name, rng string, ok bool) {
+  rest, ok := strings.CutPrefix(spec, aliasPrefix)
+  if !ok || rest == "" { return "", "", false }
+  name, rng = splitKey(rest)
+  if rng == "" { rng = "latest" }
+  return name, rng, true
+}
+
+// aliasTarget splits the version or ref of an aliased node into the real
+// package name and its version or ref.
+func aliasTarget(ref string) (name, realRef string, ok bool) {
+  rest, ok := strings.CutPrefix(ref, aliasPrefix)
+  if !ok { return "", "", false }
+  name, realRef = splitKey(rest)
+  return name, realRef, true
+}
+
+// realVersion returns the registry version behind a node version.
+func realVersion(version string) string {
+  if _, v, ok := aliasTarget(version); ok { return v }
+  return version
+}
+
+// pkgName is the registry name of n, which differs from n.Name for aliases.
+func (n *GraphNode) pkgName() string {
+  if name, _, ok := aliasTarget(n.Version); ok { return name }
+  return n.Name
+}
+
+// pkgVersion is the semver version of n: the registry version, or the
+// package.json version of a git, local or tarball URL dependency.
+func (n *GraphNode) pkgVersion() string {
+  if isSourceSpec(n.Version) && n.MD != nil { return n.MD.Version }
+  return realVersion(n.Version)
+}
+
+// isSourceSpec reports whether spec (or a node version) names a package by
+// where to get it rather than by a registry version.
+func isSourceSpec(spec string) bool {
+  return isGitSpec(spec) || isLocalSpec(spec) || isTarballURL(spec)
+}
+
+// resolveDep resolves spec for the dependency name, following aliases and
+// git, local (with absolute paths, see absLocalSpec) and tarball URL specs.
+// The returned version is the node version: npm:<name>@<version> for
+// aliases, git+<url>#<sha> for git dependencies, file:<path>#<hash> or
+// link:<path> for local ones and the URL for tarballs.
+func resolveDep(ctx context.Context, name, spec string, cache map[string]*RootDoc) (string, *PackageMetadata, error) {
+  if isGitSpec(spec) {
+    v, md, err := resolveGitDep(ctx, spec)
+    if err != nil { return "", nil, fmt.Errorf("%s@%s: %w", name, spec, err) }
+    return v, md, nil
+  }
+  if isLocalSpec(spec) {
+    v, md, err := resolveLocal(spec)
+    if err != nil { return "", nil, fmt.Errorf("%s@%s: %w", name, spec, err) }
+    return v, md, nil
+  }
+  if isTarballURL(spec) {
+    v, md, err := resolveTarballURL(ctx, spec)
+    if err != nil { return "", nil, fmt.Errorf("%s@%s: %w", name, spec, err) }
+    return v, md, nil
+  }
+  real, rng, ok := parseAlias(spec)
+  if !ok { return resolveVersionAndMetadata(ctx, name, spec, cache) }
+  v, md, err := resolveVersionAndMetadata(ctx, real, rng, cache)
+  if err != nil { return "", nil, fmt.Errorf("%s (alias of %s): %w", name, real, err) }
+  return aliasPrefix + keyOf(real, v), md, nil
+}
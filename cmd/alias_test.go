@@ -0,0 +1,52 @@
+package cmd
+
+import (
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+)
+
+func TestAliasSpecs(t *testing.T) {
+  if name, spec := splitPackageArg("sw@npm:@scope/string-width@^4"); name != "sw" || spec != "npm:@scope/string-width@^4" { t.Fatalf("split: %q %q", name, spec) }
+  if name, rng, ok := parseAlias("npm:@scope/string-width"); !ok || name != "@scope/string-width" || rng != "latest" { t.Fatalf("parse: %q %q %v", name, rng, ok) }
+  if _, _, ok := parseAlias("^1.0.0"); ok { t.Fatalf("not an alias") }
+  if got := storePkgPath("/s", "sw", "npm:string-width@4.2.3(react@18.0.0)"); got != filepath.Join("/s", "string-width", "4.2.3(react@18.0.0)") { t.Fatalf("store path %s", got) }
+  if got := saveSpec("npm:string-width@latest", "npm:string-width@4.2.3", "^"); got != "npm:string-width@^4.2.3" { t.Fatalf("saveSpec %s", got) }
+  if got := saveSpec("npm:string-width@^4", "npm:string-width@4.2.3", "^"); got != "npm:string-width@^4" { t.Fatalf("saveSpec %s", got) }
+}
+
+func TestInstallAliasedDependencies(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t,
+    testPkg{Name: "string-width", Version: "4.2.3"},
+    testPkg{Name: "string-width", Version: "5.1.2"},
+    testPkg{Name: "cliui", Version: "8.0.1", Deps: map[string]string{"string-width": "^5.0.0", "string-width-cjs": "npm:string-width@^4.2.0"}},
+  )
+  proj := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(`{"dependencies":{"cliui":"^8.0.0","sw4":"npm:string-width@^4"}}`), 0o644)
+
+  runCLI(t, "install", "--dir", proj)
+  store, _ := defaultStoreDir()
+  checkLink := func(link, want string) {
+    t.Helper()
+    got, err := os.Readlink(link)
+    if err != nil { t.Fatalf("%s: %v", link, err) }
+    if got != want { t.Fatalf("%s -> %s, want %s", link, got, want) }
+  }
+  cliui := filepath.Join(store, "cliui", "8.0.1", "node_modules")
+  checkLink(filepath.Join(cliui, "string-width-cjs"), filepath.Join(store, "string-width", "4.2.3"))
+  checkLink(filepath.Join(cliui, "string-width"), filepath.Join(store, "string-width", "5.1.2"))
+  checkLink(filepath.Join(proj, "node_modules", "sw4"), filepath.Join(store, "string-width", "4.2.3"))
+
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatal(err) }
+  lp, ok := lf.Packages["string-width-cjs@npm:string-width@4.2.3"]
+  if !ok || lp.Name != "string-width" || lp.Version != "4.2.3" || lp.Alias != "string-width-cjs" { t.Fatalf("alias not recorded: %+v", lf.Packages) }
+  if lf.RootSpecs["sw4"].Spec != "npm:string-width@^4" { t.Fatalf("root spec: %+v", lf.RootSpecs) }
+
+  // a frozen install rebuilds the aliases from the lockfile
+  _ = os.RemoveAll(filepath.Join(proj, "node_modules"))
+  runCLI(t, "install", "--frozen-lockfile", "--dir", proj)
+  checkLink(filepath.Join(proj, "node_modules", "sw4"), filepath.Join(store, "string-width", "4.2.3"))
+}
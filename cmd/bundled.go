@@ -0,0 +1,43 @@
+package cmd
+
+import (
+  "encoding/json"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+// bundled returns the sorted names of the dependencies md ships in its
+// tarball's node_modules: bundleDependencies (or bundledDependencies) lists
+// them, or is true to bundle every entry of dependencies. They are not
+// resolved or linked, so the shipped copies stay in place.
+func (md *PackageMetadata) bundled() []string {
+  raw := md.BundleDependencies
+  if len(raw) == 0 { raw = md.BundledDependencies }
+  if len(raw) == 0 { return nil }
+  var names []string
+  var all bool
+  if json.Unmarshal(raw, &all) == nil {
+    if !all { return nil }
+    for name := range md.Dependencies { names = append(names, name) }
+  } else if json.Unmarshal(raw, &names) != nil {
+    return nil
+  }
+  sort.Strings(names)
+  return names
+}
+
+func (md *PackageMetadata) isBundled(name string) bool {
+  for _, b := range md.bundled() {
+    if b == name { return true }
+  }
+  return false
+}
+
+// isDepLink reports whether rel, relative to a store entry, is where a
+// dependency is linked: node_modules/<name> or node_modules/@scope/<name>.
+func isDepLink(rel string) bool {
+  parts := strings.Split(filepath.ToSlash(rel), "/")
+  if len(parts) < 2 || parts[0] != "node_modules" { return false }
+  return len(parts) == 2 || (len(parts) == 3 && strings.HasPrefix(parts[1], "@"))
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+  "encoding/json"
+  "os"
+  "path/filepath"
+  "slices"
+  "testing"
+)
+
+func TestBundledNames(t *testing.T) {
+  for doc, want := range map[string][]string{
+    `{"dependencies":{"a":"1","b":"1"},"bundleDependencies":["b"]}`:  {"b"},
+    `{"dependencies":{"a":"1","b":"1"},"bundledDependencies":["a"]}`: {"a"},
+    `{"dependencies":{"b":"1","a":"1"},"bundleDependencies":true}`:   {"a", "b"},
+    `{"dependencies":{"a":"1"},"bundleDependencies":false}`:          nil,
+  } {
+    var md PackageMetadata
+    if err := json.Unmarshal([]byte(doc), &md); err != nil { t.Fatal(err) }
+    if got := md.bundled(); !slices.Equal(got, want) { t.Errorf("%s: got %v, want %v", doc, got, want) }
+  }
+}
+
+func TestInstallKeepsBundledDependencies(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "inner", Version: "1.0.0", Files: map[string]string{"index.js": "registry"}},
+    testPkg{Name: "other", Version: "1.0.0"},
+    testPkg{Name: "react", Version: "1.0.0"},
+    testPkg{Name: "fat", Version: "1.0.0", Deps: map[string]string{"inner": "^1.0.0", "other": "^1.0.0"},
+      Extra: map[string]any{"bundleDependencies": []string{"inner"}, "peerDependencies": map[string]string{"react": "*"}},
+      Files: map[string]string{"node_modules/inner/package.json": `{"name":"inner","version":"1.0.0"}`, "node_modules/inner/index.js": "bundled"}},
+  )
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"fat":"^1.0.0","react":"^1.0.0"}}`)
+  runCLI(t, "install", "--dir", proj)
+
+  lf := lockHas(t, proj, "fat@1.0.0(react@1.0.0)", "other@1.0.0")
+  if _, ok := lf.Packages["inner@1.0.0"]; ok { t.Fatalf("bundled inner should not be resolved: %v", lf.Packages) }
+  fat := lf.Packages["fat@1.0.0(react@1.0.0)"]
+  if !slices.Equal(fat.Bundled, []string{"inner"}) || fat.Dependencies["inner"] != "" || fat.Dependencies["other"] != "1.0.0" { t.Fatalf("fat: %+v", fat) }
+  // the peer instance keeps the shipped copy next to its dependency links
+  b, err := os.ReadFile(filepath.Join(proj, "node_modules", "fat", "node_modules", "inner", "index.js"))
+  if err != nil || string(b) != "bundled" { t.Fatalf("bundled copy: %q, %v", b, err) }
+  if _, err := os.Stat(filepath.Join(proj, "node_modules", "fat", "node_modules", "other", "package.json")); err != nil { t.Fatalf("other not linked: %v", err) }
+}
@@ -10,6 +10,14 @@ type Config struct {
   Registry    string `json:"registry"`
   StoreDir    string `json:"storeDir"`
   Concurrency int    `json:"concurrency"`
+  SavePrefix  string `json:"savePrefix,omitempty"` // range prefix for `wlim add`: ^ (default) or ~
+  SaveExact   bool   `json:"saveExact,omitempty"`
+  AutoInstallPeers bool `json:"autoInstallPeers,omitempty"`
+  OnlyBuiltDependencies []string `json:"onlyBuiltDependencies,omitempty"` // packages allowed to run install scripts
+  Workspaces []string `json:"workspaces,omitempty"` // workspace globs, instead of package.json "workspaces"
+  MinimumReleaseAge int `json:"minimumReleaseAge,omitempty"` // hours; younger versions are not resolved
+  NodeVersion string `json:"nodeVersion,omitempty"` // Node.js version engines.node is checked against, instead of `node --version`
+  EngineStrict bool `json:"engineStrict,omitempty"` // unsupported engines fail the install
 }
 
 func loadConfig(projectDir string) (*Config, error) {
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "time"
+
+  "github.com/spf13/cobra"
+)
+
+// releaseCutoff hides versions published after it from resolution; it is
+// set from --before and the minimumReleaseAge setting. Zero means no cutoff.
+// Versions already in wlim.lock are installed as locked.
+var releaseCutoff time.Time
+
+func addCutoffFlag(cmd *cobra.Command) {
+  cmd.Flags().String("before", "", "Only resolve versions published before this date (e.g. 2026-01-01T00:00:00Z or 2026-01-01)")
+}
+
+// applyCutoff sets releaseCutoff from --before and cfg.MinimumReleaseAge,
+// whichever is earlier. It reports whether --before was given.
+func applyCutoff(cmd *cobra.Command, cfg *Config) (bool, error) {
+  releaseCutoff = time.Time{}
+  if cfg.MinimumReleaseAge > 0 {
+    releaseCutoff = time.Now().Add(-time.Duration(cfg.MinimumReleaseAge) * time.Hour)
+  }
+  s, _ := cmd.Flags().GetString("before")
+  if s == "" { return false, nil }
+  before, err := parseCutoff(s)
+  if err != nil { return false, err }
+  if releaseCutoff.IsZero() || before.Before(releaseCutoff) { releaseCutoff = before }
+  return true, nil
+}
+
+// parseCutoff accepts an RFC 3339 time or a date, taken as midnight UTC.
+func parseCutoff(s string) (time.Time, error) {
+  for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+    if t, err := time.Parse(layout, s); err == nil { return t, nil }
+  }
+  return time.Time{}, fmt.Errorf("invalid --before date %q (want e.g. 2026-01-01T00:00:00Z)", s)
+}
+
+// released reports whether version was published before releaseCutoff.
+// While a cutoff is set, versions without a publish time are not resolved.
+func (rd *RootDoc) released(version string) bool {
+  if releaseCutoff.IsZero() { return true }
+  t, err := time.Parse(time.RFC3339, rd.Time[version])
+  return err == nil && t.Before(releaseCutoff)
+}
+
+// releasedVersions lists the versions of rd that may be resolved.
+func (rd *RootDoc) releasedVersions() []string {
+  out := make([]string, 0, len(rd.Versions))
+  for v := range rd.Versions {
+    if rd.released(v) { out = append(out, v) }
+  }
+  return out
+}
+
+// releasedVersion is released for a registry version of name.
+func releasedVersion(ctx context.Context, name, version string, cache map[string]*RootDoc) bool {
+  if releaseCutoff.IsZero() { return true }
+  rd, err := fetchRootDoc(ctx, name, cache)
+  return err == nil && rd.released(version)
+}
+
+func cutoffString() string { return releaseCutoff.UTC().Format(time.RFC3339) }
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+  "context"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestResolveBeforeCutoff(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0", Time: "2025-06-01T00:00:00.000Z"},
+    testPkg{Name: "a", Version: "1.1.0", Time: "2025-12-01T00:00:00.000Z"},
+    testPkg{Name: "a", Version: "2.0.0-beta.1", Time: "2026-01-15T00:00:00.000Z", Tags: []string{"next"}},
+    testPkg{Name: "a", Version: "1.2.0", Time: "2026-02-01T00:00:00.000Z"},
+    testPkg{Name: "a", Version: "1.3.0"},
+  )
+  t.Cleanup(func() { releaseCutoff = time.Time{} })
+  cutoff, err := parseCutoff("2026-01-01")
+  if err != nil { t.Fatal(err) }
+  releaseCutoff = cutoff
+  cache := map[string]*RootDoc{}
+  for spec, want := range map[string]string{"": "1.1.0", "latest": "1.1.0", "^1.0.0": "1.1.0", "~1.0.0": "1.0.0"} {
+    v, _, err := resolveVersionAndMetadata(context.Background(), "a", spec, cache)
+    if err != nil || v != want { t.Errorf("a@%s: got %s, %v; want %s", spec, v, err, want) }
+  }
+  for _, spec := range []string{"next", "1.2.0", "1.3.0", "^1.2.0"} {
+    if v, _, err := resolveVersionAndMetadata(context.Background(), "a", spec, cache); err == nil || !strings.Contains(err.Error(), "2026-01-01T00:00:00Z") {
+      t.Errorf("a@%s: got %s, %v; want a cutoff error", spec, v, err)
+    }
+  }
+  if _, err := parseCutoff("yesterday"); err == nil { t.Errorf("invalid date should fail") }
+}
+
+func TestInstallBeforeAndMinimumReleaseAge(t *testing.T) {
+  now := time.Now().UTC()
+  hoursAgo := func(h int) string { return now.Add(-time.Duration(h) * time.Hour).Format(time.RFC3339) }
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0", Time: "2025-06-01T00:00:00Z"},
+    testPkg{Name: "a", Version: "1.1.0", Time: "2025-12-01T00:00:00Z"},
+    testPkg{Name: "a", Version: "1.2.0", Time: hoursAgo(100)},
+    testPkg{Name: "a", Version: "1.3.0", Time: hoursAgo(2)},
+    testPkg{Name: "b", Version: "1.0.0", Deps: map[string]string{"a": "^1.0.0"}, Time: "2025-01-01T00:00:00Z"},
+  )
+  t.Cleanup(func() { releaseCutoff = time.Time{} })
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"b":"^1.0.0"}}`)
+  runCLI(t, "install", "--dir", proj, "--before", "2026-01-01T00:00:00Z")
+  lockHas(t, proj, "b@1.0.0", "a@1.1.0")
+
+  // --before re-resolves even when wlim.lock is current
+  runCLI(t, "install", "--dir", proj, "--before", "2025-07-01")
+  lockHas(t, proj, "a@1.0.0")
+  runCLI(t, "update", "--dir", proj, "--before", "2026-01-01")
+  lockHas(t, proj, "a@1.1.0")
+
+  writeTestFile(t, filepath.Join(proj, "wlim.json"), `{"minimumReleaseAge": 24}`)
+  runCLI(t, "update", "--dir", proj)
+  lockHas(t, proj, "a@1.2.0")
+}
+
+func TestAddBeforeCutoff(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.4.0", Time: "2025-06-01T00:00:00Z"},
+    testPkg{Name: "a", Version: "2.0.0", Time: "2026-06-01T00:00:00Z"},
+  )
+  t.Cleanup(func() { releaseCutoff = time.Time{} })
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"name":"app"}`)
+  runCLI(t, "add", "--dir", proj, "a", "--before", "2026-01-01")
+  m, err := readProjectManifest(proj)
+  if err != nil { t.Fatal(err) }
+  if m.Dependencies["a"] != "^1.4.0" { t.Fatalf("saved spec %q, want ^1.4.0", m.Dependencies["a"]) }
+  lockHas(t, proj, "a@1.4.0")
+}
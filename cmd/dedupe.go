@@ -0,0 +1,215 @@
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+  "time"
+
+  "github.com/spf13/cobra"
+)
+
+// versionPool holds the registry versions already selected per package: those
+// of the graph being resolved and, when seeded, of the lockfile it replaces.
+// resolveGraph reuses the highest pooled version that satisfies a range before
+// picking from the registry, so roots share versions and re-resolving does
+// not churn.
+type versionPool map[string]map[string]bool
+
+// lockVersionPool seeds a pool with the registry versions in lf.
+func lockVersionPool(lf *LockFile) versionPool {
+  pool := versionPool{}
+  for k := range lf.Packages {
+    name, ref := splitKey(k)
+    pool.add(name, refVersion(ref))
+  }
+  return pool
+}
+
+// add records a node version of name; aliases count for the real package and
+// git, local and tarball URL versions are ignored.
+func (p versionPool) add(name, version string) {
+  if p == nil || isSourceSpec(version) { return }
+  if real, v, ok := aliasTarget(version); ok { name, version = real, v }
+  if p[name] == nil { p[name] = map[string]bool{} }
+  p[name][version] = true
+}
+
+func (p versionPool) clone() versionPool {
+  out := versionPool{}
+  for name, vs := range p {
+    for v := range vs { out.add(name, v) }
+  }
+  return out
+}
+
+// pick returns the highest pooled version of name within rng.
+func (p versionPool) pick(name, rng string) (string, bool) {
+  if len(p[name]) == 0 { return "", false }
+  r, err := parseRange(rng)
+  if err != nil { return "", false }
+  versions := make([]string, 0, len(p[name]))
+  for v := range p[name] { versions = append(versions, v) }
+  v := maxSatisfying(versions, r)
+  return v, v != ""
+}
+
+// dedupePool returns the fewest registry versions per package that satisfy
+// every range in the graph of lf: greedily the version covering the most
+// ranges still uncovered, the highest one on ties.
+func dedupePool(lf *LockFile, nodes map[string]*GraphNode) versionPool {
+  candidates := versionPool{}
+  ranges := map[string][]npmRange{}
+  want := func(name, spec string) {
+    if real, rng, ok := parseAlias(spec); ok { name, spec = real, rng }
+    if spec == "" || isSourceSpec(spec) { return }
+    if r, err := parseRange(spec); err == nil { ranges[name] = append(ranges[name], r) }
+  }
+  for _, n := range nodes {
+    candidates.add(n.Name, n.Version)
+    if n.MD == nil { continue }
+    for dep, spec := range n.MD.dependencySpecs() {
+      if _, ok := n.Deps[dep]; ok { want(dep, spec) }
+    }
+  }
+  rootSpecs := []map[string]LockRoot{lf.RootSpecs}
+  for _, li := range lf.Importers { rootSpecs = append(rootSpecs, li.RootSpecs) }
+  for _, specs := range rootSpecs {
+    for name, rs := range specs {
+      if rs.Link == "" { want(name, rs.Spec) }
+    }
+  }
+  pool := versionPool{}
+  for name, rs := range ranges {
+    versions := make([]*npmVersion, 0, len(candidates[name]))
+    for s := range candidates[name] {
+      if v, err := parseVersion(s); err == nil { versions = append(versions, v) }
+    }
+    // highest first, so ties keep the newer version
+    sort.Slice(versions, func(i, j int) bool { return versions[i].compare(versions[j]) > 0 })
+    for len(rs) > 0 {
+      var best *npmVersion
+      bestN := 0
+      for _, v := range versions {
+        n := 0
+        for _, r := range rs {
+          if r.test(v) { n++ }
+        }
+        if n > bestN { best, bestN = v, n }
+      }
+      if best == nil { break }
+      pool.add(name, versionString(candidates[name], best))
+      left := rs[:0]
+      for _, r := range rs {
+        if !r.test(best) { left = append(left, r) }
+      }
+      rs = left
+    }
+  }
+  return pool
+}
+
+// versionString returns the spelling of v among versions, which may be loose.
+func versionString(versions map[string]bool, v *npmVersion) string {
+  for s := range versions {
+    if pv, err := parseVersion(s); err == nil && pv.compare(v) == 0 { return s }
+  }
+  return v.String()
+}
+
+// lockRootDeps rebuilds the root dependencies of every importer of lf from
+// the specs recorded in it.
+func lockRootDeps(projectDir string, lf *LockFile) []*importer {
+  deps := func(dir string, roots []string, specs map[string]LockRoot) []rootDep {
+    var out []rootDep
+    seen := map[string]bool{}
+    for _, k := range roots {
+      name, ref := splitKey(k)
+      d := rootDep{Name: name, Spec: refVersion(ref), Section: sectionProd}
+      if rs, ok := specs[name]; ok { d.Spec, d.Section = rs.Spec, rs.Section }
+      if d.Section == "" { d.Section = sectionProd }
+      d.Dir = filepath.Join(projectDir, filepath.FromSlash(dir))
+      out = append(out, d)
+      seen[name] = true
+    }
+    names := make([]string, 0, len(specs))
+    for name := range specs { names = append(names, name) }
+    sort.Strings(names)
+    for _, name := range names {
+      rs := specs[name]
+      if seen[name] { continue }
+      out = append(out, rootDep{Name: name, Spec: rs.Spec, Section: rs.Section, Link: rs.Link, Dir: filepath.Join(projectDir, filepath.FromSlash(dir))})
+    }
+    return out
+  }
+  importers := []*importer{{Dir: ".", Deps: deps(".", lf.Roots, lf.RootSpecs)}}
+  for _, d := range sortedImporterDirs(lf) {
+    li := lf.Importers[d]
+    importers = append(importers, &importer{Dir: d, Deps: deps(d, li.Roots, li.RootSpecs)})
+  }
+  return importers
+}
+
+// dedupeLockfile re-resolves the roots recorded in wlim.lock with the fewest
+// versions that satisfy the graph's ranges and rewrites the lockfile. It
+// returns the number of package versions before and after.
+func dedupeLockfile(ctx context.Context, projectDir string, cache map[string]*RootDoc) (int, int, error) {
+  lf, err := readLockfile(projectDir)
+  if err != nil { return 0, 0, err }
+  nodes, _, err := nodesFromLock(ctx, lf, cache)
+  if err != nil { return 0, 0, err }
+  // the ranges of the graph come from the registry metadata
+  for _, n := range nodes {
+    if isSourceSpec(n.Version) { continue }
+    md, err := metadataForExactVersion(ctx, n.pkgName(), n.pkgVersion(), cache)
+    if err != nil && !n.Optional { return 0, 0, err }
+    if err == nil { n.MD = md }
+  }
+  importers := lockRootDeps(projectDir, lf)
+  res := newResolver(dedupePool(lf, nodes))
+  if res.overrides, err = parseOverrides(lf.Overrides, projectDir); err != nil { return 0, 0, err }
+  all, err := resolveImporters(ctx, importers, cache, res)
+  if err != nil { return 0, 0, err }
+  if err := writeImportersLockfile(projectDir, importers, all, lf.Overrides); err != nil { return 0, 0, err }
+  return countVersions(nodes), countVersions(all), nil
+}
+
+// countVersions counts the distinct name@version pairs of nodes, ignoring
+// peer instances.
+func countVersions(nodes map[string]*GraphNode) int {
+  seen := map[string]bool{}
+  for _, n := range nodes { seen[keyOf(n.Name, n.Version)] = true }
+  return len(seen)
+}
+
+var dedupeCmd = &cobra.Command{
+  Use:   "dedupe",
+  Short: "Rewrite wlim.lock to use the fewest versions of each package",
+  Run: func(cmd *cobra.Command, args []string) {
+    projectDir, _ := cmd.Flags().GetString("dir")
+    if projectDir == "" { projectDir = "." }
+    cfg, _ := loadConfig(projectDir)
+    if r, _ := cmd.Flags().GetString("registry"); r != "" { registryOverride = r }
+    if registryOverride == "" && cfg.Registry != "" { registryOverride = cfg.Registry }
+    autoInstallPeers, _ = cmd.Flags().GetBool("auto-install-peers")
+    autoInstallPeers = autoInstallPeers || cfg.AutoInstallPeers
+
+    ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+    defer cancel()
+    before, after, err := dedupeLockfile(ctx, projectDir, make(map[string]*RootDoc))
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    fmt.Printf("Deduped wlim.lock: %d -> %d package versions. Run wlim install to apply.\n", before, after)
+  },
+}
+
+func init() {
+  dedupeCmd.Flags().String("dir", ".", "Project directory containing wlim.lock")
+  dedupeCmd.Flags().String("registry", "", "Override npm registry base URL (takes precedence over WLIM_REGISTRY)")
+  dedupeCmd.Flags().Bool("auto-install-peers", false, "Install missing peer dependencies automatically")
+  rootCmd.AddCommand(dedupeCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+  "path/filepath"
+  "testing"
+)
+
+func lockHas(t *testing.T, proj string, keys ...string) *LockFile {
+  t.Helper()
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatal(err) }
+  for _, k := range keys {
+    if _, ok := lf.Packages[k]; !ok { t.Fatalf("lockfile lacks %s: %v", k, lf.Packages) }
+  }
+  return lf
+}
+
+func TestDedupeLockfile(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "lodash", Version: "4.17.15"},
+    testPkg{Name: "lodash", Version: "4.17.21"},
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"lodash": "^4.17.0"}},
+    testPkg{Name: "b", Version: "1.0.0", Deps: map[string]string{"lodash": "4.17.15"}},
+    testPkg{Name: "c", Version: "1.0.0", Deps: map[string]string{"lodash": "^4.17.15"}},
+  )
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"a":"1.0.0","b":"1.0.0"}}`)
+  runCLI(t, "install", "--dir", proj)
+  lockHas(t, proj, "lodash@4.17.21", "lodash@4.17.15")
+
+  runCLI(t, "dedupe", "--dir", proj)
+  lf := lockHas(t, proj, "a@1.0.0", "b@1.0.0", "lodash@4.17.15")
+  if len(lf.Packages) != 3 { t.Fatalf("dedupe kept extra versions: %v", lf.Packages) }
+  // the lockfile still matches package.json, so install keeps it
+  runCLI(t, "install", "--frozen-lockfile", "--dir", proj)
+
+  // a new root reuses the version already locked instead of the highest one
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"a":"1.0.0","b":"1.0.0","c":"1.0.0"}}`)
+  runCLI(t, "install", "--dir", proj)
+  lf = lockHas(t, proj, "c@1.0.0", "lodash@4.17.15")
+  if _, ok := lf.Packages["lodash@4.17.21"]; ok { t.Fatalf("c should reuse lodash@4.17.15: %v", lf.Packages) }
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "sort"
+  "strings"
+)
+
+// deprecation is the "deprecated" field of a version: the message, or ""
+// when the version is not deprecated. Some packuments use booleans.
+type deprecation string
+
+func (d *deprecation) UnmarshalJSON(b []byte) error {
+  var flag bool
+  if json.Unmarshal(b, &flag) == nil {
+    *d = ""
+    if flag { *d = "deprecated" }
+    return nil
+  }
+  var s string
+  if err := json.Unmarshal(b, &s); err != nil { return err }
+  *d = deprecation(s)
+  return nil
+}
+
+// deprecatedUse is a deprecated version selected by resolveGraph, with the
+// chain of dependents that pulled it in.
+type deprecatedUse struct {
+  key, message string
+  path         []string
+}
+
+// noteDeprecated records n when its version is deprecated.
+func (r *resolver) noteDeprecated(n *GraphNode, path []string) {
+  if n.MD == nil || n.MD.Deprecated == "" { return }
+  r.deprecated = append(r.deprecated, deprecatedUse{key: n.key(), message: string(n.MD.Deprecated), path: path})
+}
+
+// printDeprecations warns once per deprecated version recorded since the
+// last call, with the first chain that reached it.
+func (r *resolver) printDeprecations() {
+  seen := map[string]bool{}
+  for _, d := range r.deprecated {
+    if seen[d.key] { continue }
+    seen[d.key] = true
+    fmt.Printf("Warning: %s is deprecated: %s\n  %s\n", d.key, d.message, strings.Join(d.path, " > "))
+  }
+  r.deprecated = nil
+}
+
+// lockDeprecations returns the deprecated packages of lf, sorted by key,
+// each with the first chain from a root (prefixed by its workspace dir) that
+// reaches it.
+func lockDeprecations(lf *LockFile) []deprecatedUse {
+  paths := map[string][]string{}
+  var queue []string
+  visit := func(key string, path []string) {
+    if _, ok := paths[key]; ok { return }
+    if _, ok := lf.Packages[key]; !ok { return }
+    paths[key] = path
+    queue = append(queue, key)
+  }
+  for _, r := range lf.Roots { visit(r, []string{r}) }
+  for _, dir := range sortedImporterDirs(lf) {
+    for _, r := range lf.Importers[dir].Roots { visit(r, []string{dir, r}) }
+  }
+  for len(queue) > 0 {
+    k := queue[0]
+    queue = queue[1:]
+    deps := lf.Packages[k].Dependencies
+    names := make([]string, 0, len(deps))
+    for name := range deps { names = append(names, name) }
+    sort.Strings(names)
+    for _, name := range names {
+      dk := keyOf(name, deps[name])
+      visit(dk, appendPath(paths[k], dk))
+    }
+  }
+  var out []deprecatedUse
+  for k, p := range lf.Packages {
+    if p.Deprecated != "" { out = append(out, deprecatedUse{key: k, message: p.Deprecated, path: paths[k]}) }
+  }
+  sort.Slice(out, func(i, j int) bool { return out[i].key < out[j].key })
+  return out
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestDeprecatedWarningsAndList(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "request", Version: "2.88.2", Extra: map[string]any{"deprecated": "request has been deprecated"}},
+    testPkg{Name: "b", Version: "1.0.0", Deps: map[string]string{"request": "^2.0.0", "fine": "^1.0.0"}},
+    testPkg{Name: "fine", Version: "1.0.0", Extra: map[string]any{"deprecated": false}},
+  )
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"b":"^1.0.0"}}`)
+  out := captureStdout(t, func() { runCLI(t, "install", "--dir", proj) })
+  if !strings.Contains(out, "Warning: request@2.88.2 is deprecated: request has been deprecated\n  b@1.0.0 > request@2.88.2\n") { t.Fatalf("missing deprecation warning:\n%s", out) }
+  if strings.Count(out, "is deprecated") != 1 { t.Fatalf("want one deprecation warning:\n%s", out) }
+
+  lf := lockHas(t, proj, "request@2.88.2", "fine@1.0.0")
+  if lf.Packages["request@2.88.2"].Deprecated != "request has been deprecated" || lf.Packages["fine@1.0.0"].Deprecated != "" { t.Fatalf("lockfile deprecations: %+v", lf.Packages) }
+  out = captureStdout(t, func() { runCLI(t, "list", "--dir", proj, "--deprecated") })
+  if want := "Deprecated:\n  request@2.88.2: request has been deprecated\n    b@1.0.0 > request@2.88.2\n"; out != want { t.Fatalf("list --deprecated:\n%s\nwant:\n%s", out, want) }
+}
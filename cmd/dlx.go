@@ -0,0 +1,91 @@
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "path/filepath"
+  "runtime"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+)
+
+// dlxProject installs name@spec into a throwaway project under the cache,
+// backed by the shared store, and returns its directory and root package.
+// A project whose lockfile was written is complete and reused as is.
+func dlxProject(ctx context.Context, name, spec string) (string, *GraphNode, error) {
+  cache := make(map[string]*RootDoc)
+  nodes, roots, rootSpecs, err := resolveRoots(ctx, []rootDep{{Name: name, Spec: spec, Section: sectionProd}}, cache, nil)
+  if err != nil { return "", nil, err }
+  if len(roots) == 0 { return "", nil, fmt.Errorf("%s@%s could not be installed", name, spec) }
+  root := roots[0]
+  base, err := cacheBaseDir()
+  if err != nil { return "", nil, err }
+  dir := filepath.Join(base, "dlx", strings.ReplaceAll(root.key(), "/", "+"))
+  if _, err := os.Stat(filepath.Join(dir, "wlim.lock")); err == nil {
+    logf("Reusing %s\n", dir)
+    return dir, root, nil
+  }
+  storeDir, err := defaultStoreDir()
+  if err != nil { return "", nil, err }
+  if err := ensureDir(dir); err != nil { return "", nil, err }
+  if err := installGraph(ctx, dir, storeDir, roots, nodes, runtime.NumCPU()); err != nil { return "", nil, err }
+  if err := writeLockfile(dir, roots, nodes, rootSpecs); err != nil { return "", nil, err }
+  return dir, root, nil
+}
+
+// dlxBin picks the binary to run from a package: its only bin, or the one
+// named like the package (without scope).
+func dlxBin(pj *pkgJSON, name string) (string, error) {
+  if len(pj.Bin) == 1 {
+    for b := range pj.Bin { return b, nil }
+  }
+  short := name
+  if i := strings.LastIndex(short, "/"); i >= 0 { short = short[i+1:] }
+  if _, ok := pj.Bin[short]; ok { return short, nil }
+  if len(pj.Bin) == 0 { return "", fmt.Errorf("%s has no binaries", name) }
+  bins := make([]string, 0, len(pj.Bin))
+  for b := range pj.Bin { bins = append(bins, b) }
+  sort.Strings(bins)
+  return "", fmt.Errorf("%s has several binaries (%s); use wlim exec with --dir", name, strings.Join(bins, ", "))
+}
+
+var dlxCmd = &cobra.Command{
+  Use:   "dlx <package>[@version|@range] [<args>...]",
+  Short: "Run a package's binary without adding it to the project",
+  Long:  "Install a package into a cached temporary project backed by the shared store and run its binary in the current directory.",
+  Args:  cobra.MinimumNArgs(1),
+  Run: func(cmd *cobra.Command, args []string) {
+    if r, _ := cmd.Flags().GetString("registry"); r != "" { registryOverride = r }
+    name, spec := splitPackageArg(args[0])
+    ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+    dir, root, err := dlxProject(ctx, name, spec)
+    cancel()
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    storeDir, _ := defaultStoreDir()
+    pj, err := readPackageJSON(storePkgPath(storeDir, root.Name, root.ref()))
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    bin, err := dlxBin(pj, root.Name)
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    pathDirs := append([]string{filepath.Join(dir, "node_modules", ".bin")}, binPathDirs(".")...)
+    exitOnError(execBin(context.Background(), ".", pathDirs, bin, passthroughArgs(args), stdIO()))
+  },
+}
+
+func init() {
+  dlxCmd.Flags().String("registry", "", "Override npm registry base URL (takes precedence over WLIM_REGISTRY)")
+  dlxCmd.Flags().SetInterspersed(false)
+  rootCmd.AddCommand(dlxCmd)
+}
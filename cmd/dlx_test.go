@@ -0,0 +1,31 @@
+package cmd
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+)
+
+func TestDlxInstallsIntoCachedProject(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("bins are shell scripts") }
+  newTestRegistry(t, testPkg{Name: "@gen/create-app", Version: "1.0.0",
+    Extra: map[string]any{"bin": map[string]string{"create-app": "bin/create-app"}},
+    Files: map[string]string{"bin/create-app": "#!/bin/sh\necho \"created $1\" > \"$2\"\n"}})
+  ctx := context.Background()
+  dir, root, err := dlxProject(ctx, "@gen/create-app", "latest")
+  if err != nil { t.Fatalf("dlx: %v", err) }
+  store, _ := defaultStoreDir()
+  pj, err := readPackageJSON(storePkgPath(store, root.Name, root.ref()))
+  if err != nil { t.Fatal(err) }
+  bin, err := dlxBin(pj, root.Name)
+  if err != nil || bin != "create-app" { t.Fatalf("bin = %q, %v", bin, err) }
+
+  out := filepath.Join(t.TempDir(), "out")
+  if err := execBin(ctx, ".", []string{filepath.Join(dir, "node_modules", ".bin")}, bin, []string{"demo", out}, stdIO()); err != nil { t.Fatalf("exec: %v", err) }
+  if b, _ := os.ReadFile(out); string(b) != "created demo\n" { t.Fatalf("unexpected output %q", b) }
+
+  again, _, err := dlxProject(ctx, "@gen/create-app", "^1.0.0")
+  if err != nil || again != dir { t.Fatalf("second run should reuse %s, got %s (%v)", dir, again, err) }
+}
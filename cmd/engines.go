@@ -0,0 +1,107 @@
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "os/exec"
+  "sort"
+  "strings"
+
+  "github.com/spf13/cobra"
+)
+
+// engines is the "engines" field of a version. Old packuments sometimes have
+// an array there, which is ignored like npm does.
+type engines map[string]string
+
+func (e *engines) UnmarshalJSON(b []byte) error {
+  var m map[string]string
+  if json.Unmarshal(b, &m) != nil { m = nil }
+  *e = m
+  return nil
+}
+
+func addEngineFlags(cmd *cobra.Command) {
+  cmd.Flags().String("node-version", "", "Check engines.node against this Node.js version instead of `node --version`")
+  cmd.Flags().Bool("engine-strict", false, "Fail instead of warning when a package does not support the Node.js version")
+}
+
+// engineTarget returns the Node.js version to check engines.node against
+// (--node-version, then nodeVersion in wlim.json, then the node on PATH; ""
+// when none is found) and whether a mismatch is an error.
+func engineTarget(cmd *cobra.Command, cfg *Config) (string, bool) {
+  strict, _ := cmd.Flags().GetBool("engine-strict")
+  strict = strict || cfg.EngineStrict
+  if v, _ := cmd.Flags().GetString("node-version"); v != "" { return v, strict }
+  if cfg.NodeVersion != "" { return cfg.NodeVersion, strict }
+  out, err := exec.Command("node", "--version").Output()
+  if err != nil { return "", strict }
+  return strings.TrimSpace(string(out)), strict
+}
+
+// checkEngines reports the packages whose engines.node excludes nodeVersion,
+// with the chain from a root that reaches them. Mismatches are warnings
+// unless strict, where those of non-optional packages fail the install.
+// Skipped packages and those not built for this platform are not checked;
+// callers pass only the nodes they install.
+func checkEngines(importers []*importer, nodes map[string]*GraphNode, nodeVersion string, strict bool) error {
+  if nodeVersion == "" {
+    logf("Node.js not found; skipping engines check\n")
+    return nil
+  }
+  have, err := parseVersion(nodeVersion)
+  if err != nil { return fmt.Errorf("invalid Node.js version %q", nodeVersion) }
+  chains := nodeChains(importers, nodes)
+  keys := make([]string, 0, len(nodes))
+  for k := range nodes { keys = append(keys, k) }
+  sort.Strings(keys)
+  target := currentTarget()
+  var failed []string
+  for _, k := range keys {
+    n := nodes[k]
+    if n.MD == nil || n.MD.Engines["node"] == "" { continue }
+    if n.Skipped != "" || !target.supports(n.MD) { continue }
+    want := n.MD.Engines["node"]
+    r, err := parseRange(want)
+    if err != nil || r.test(have) { continue }
+    msg := fmt.Sprintf("%s requires node %s (have %s)", k, want, nodeVersion)
+    if c := chains[k]; len(c) > 1 { msg += "\n  " + strings.Join(c, " > ") }
+    if strict && !n.Optional {
+      failed = append(failed, msg)
+      continue
+    }
+    fmt.Println("Warning:", msg)
+  }
+  if len(failed) > 0 { return fmt.Errorf("unsupported engine (engineStrict):\n%s", strings.Join(failed, "\n")) }
+  return nil
+}
+
+// nodeChains returns, for every node reachable from the importers' roots,
+// the first chain of keys that reaches it, prefixed by the workspace dir.
+func nodeChains(importers []*importer, nodes map[string]*GraphNode) map[string][]string {
+  chains := map[string][]string{}
+  var queue []*GraphNode
+  for _, imp := range importers {
+    for _, r := range imp.Roots {
+      if _, ok := chains[r.key()]; ok { continue }
+      chains[r.key()] = []string{r.key()}
+      if imp.Dir != "." { chains[r.key()] = []string{imp.Dir, r.key()} }
+      queue = append(queue, r)
+    }
+  }
+  for len(queue) > 0 {
+    n := queue[0]
+    queue = queue[1:]
+    names := make([]string, 0, len(n.Deps))
+    for name := range n.Deps { names = append(names, name) }
+    sort.Strings(names)
+    for _, name := range names {
+      dk := keyOf(name, n.Deps[name])
+      d, ok := nodes[dk]
+      if _, seen := chains[dk]; seen || !ok { continue }
+      chains[dk] = appendPath(chains[n.key()], dk)
+      queue = append(queue, d)
+    }
+  }
+  return chains
+}
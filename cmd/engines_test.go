@@ -0,0 +1,64 @@
+package cmd
+
+import (
+  "encoding/json"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestEnginesField(t *testing.T) {
+  var md PackageMetadata
+  if err := json.Unmarshal([]byte(`{"name":"old","version":"0.1.0","engines":["node >=0.4"]}`), &md); err != nil { t.Fatalf("array engines: %v", err) }
+  if md.Engines != nil { t.Errorf("array engines should be ignored: %v", md.Engines) }
+  if err := json.Unmarshal([]byte(`{"engines":{"node":">=18"}}`), &md); err != nil || md.Engines["node"] != ">=18" { t.Errorf("engines: %v, %v", md.Engines, err) }
+}
+
+func TestCheckEngines(t *testing.T) {
+  node := func(name, version, want string, deps map[string]string) *GraphNode {
+    return &GraphNode{Name: name, Version: version, MD: &PackageMetadata{Name: name, Version: version, Engines: engines{"node": want}}, Deps: deps}
+  }
+  app := node("app", "1.0.0", "", map[string]string{"lib": "2.0.0", "opt": "1.0.0"})
+  nodes := map[string]*GraphNode{
+    "app@1.0.0": app,
+    "lib@2.0.0": node("lib", "2.0.0", ">=20.0.0", nil),
+    "opt@1.0.0": node("opt", "1.0.0", "^16 || ^22", nil),
+  }
+  nodes["opt@1.0.0"].Optional = true
+  importers := []*importer{{Dir: ".", Roots: []*GraphNode{app}}}
+
+  if err := checkEngines(importers, nodes, "v22.1.0", true); err != nil { t.Fatalf("node 22: %v", err) }
+  var err error
+  out := captureStdout(t, func() { err = checkEngines(importers, nodes, "18.19.0", false) })
+  if err != nil { t.Fatal(err) }
+  if !strings.Contains(out, "Warning: lib@2.0.0 requires node >=20.0.0 (have 18.19.0)\n  app@1.0.0 > lib@2.0.0\n") || !strings.Contains(out, "opt@1.0.0 requires node") { t.Fatalf("warnings:\n%s", out) }
+
+  // strict fails on required packages only
+  out = captureStdout(t, func() { err = checkEngines(importers, nodes, "18.19.0", true) })
+  if err == nil || !strings.Contains(err.Error(), "lib@2.0.0 requires node >=20.0.0") || strings.Contains(err.Error(), "opt@") { t.Fatalf("strict: %v", err) }
+  if !strings.Contains(out, "Warning: opt@1.0.0") { t.Fatalf("optional mismatch should warn:\n%s", out) }
+  if err := checkEngines(importers, nodes, "", true); err != nil { t.Errorf("unknown node version should skip the check: %v", err) }
+}
+
+func TestInstallChecksEngines(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "lib", Version: "2.0.0", Extra: map[string]any{"engines": map[string]string{"node": ">=20"}}},
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"lib": "^2.0.0"}},
+  )
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"a":"^1.0.0"}}`)
+  out := captureStdout(t, func() { runCLI(t, "install", "--dir", proj, "--node-version", "18.19.0") })
+  if !strings.Contains(out, "Warning: lib@2.0.0 requires node >=20 (have 18.19.0)\n  a@1.0.0 > lib@2.0.0\n") { t.Fatalf("missing engines warning:\n%s", out) }
+
+  writeTestFile(t, filepath.Join(proj, "wlim.json"), `{"nodeVersion": "20.11.1", "engineStrict": true}`)
+  out = captureStdout(t, func() { runCLI(t, "install", "--dir", proj) })
+  if strings.Contains(out, "requires node") { t.Fatalf("node 20 should satisfy >=20:\n%s", out) }
+
+  // --prod does not install devDependencies, so their engines are not checked
+  dev := t.TempDir()
+  writeTestFile(t, filepath.Join(dev, "package.json"), `{"devDependencies":{"lib":"^2.0.0"}}`)
+  out = captureStdout(t, func() { runCLI(t, "install", "--dir", dev, "--prod", "--engine-strict", "--node-version", "18.19.0") })
+  if strings.Contains(out, "requires node") { t.Fatalf("devDependency engines checked under --prod:\n%s", out) }
+  if _, err := os.Stat(filepath.Join(dev, "node_modules", "lib")); !os.IsNotExist(err) { t.Fatalf("devDependency installed under --prod: %v", err) }
+}
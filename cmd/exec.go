@@ -0,0 +1,71 @@
+package cmd
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "runtime"
+
+  "github.com/spf13/cobra"
+)
+
+// findBin looks name up in dirs first and then on PATH.
+func findBin(dirs []string, name string) (string, error) {
+  exts := []string{""}
+  if runtime.GOOS == "windows" { exts = []string{".cmd", ".exe", ""} }
+  for _, d := range dirs {
+    for _, ext := range exts {
+      p := filepath.Join(d, name+ext)
+      if st, err := os.Stat(p); err == nil && !st.IsDir() { return p, nil }
+    }
+  }
+  return exec.LookPath(name)
+}
+
+// execBin runs a binary with pathDirs on PATH and the script environment of
+// the package.json in dir, if there is one.
+func execBin(ctx context.Context, dir string, pathDirs []string, name string, args []string, sio scriptIO) error {
+  bin, err := findBin(pathDirs, name)
+  if err != nil { return fmt.Errorf("%s: command not found", name) }
+  pj, _ := readPackageJSON(dir)
+  c := exec.CommandContext(ctx, bin, args...)
+  c.Env = scriptEnv(dir, pj, "", "", pathDirs)
+  c.Stdin, c.Stdout, c.Stderr = sio.Stdin, sio.Stdout, sio.Stderr
+  return c.Run()
+}
+
+// exitOnError reports err, if any, and exits with the command's status.
+func exitOnError(err error) {
+  if err == nil { return }
+  var ee *exec.ExitError
+  if !errors.As(err, &ee) { fmt.Println("Error:", err) }
+  os.Exit(exitCode(err))
+}
+
+var execCmd = &cobra.Command{
+  Use:   "exec <bin> [<args>...]",
+  Short: "Run a binary from the project's node_modules/.bin",
+  Args:  cobra.MinimumNArgs(1),
+  Run: func(cmd *cobra.Command, args []string) {
+    projectDir, _ := cmd.Flags().GetString("dir")
+    if projectDir == "" { projectDir = "." }
+    if recursiveSelected() {
+      err := runInWorkspaces(projectDir, "", func(dir string, sio scriptIO) error {
+        return execBin(context.Background(), dir, binPathDirs(dir), args[0], passthroughArgs(args), sio)
+      })
+      if err != nil { fmt.Println("Error:", err) }
+      exitOnError(err)
+      return
+    }
+    exitOnError(execBin(context.Background(), projectDir, binPathDirs(projectDir), args[0], passthroughArgs(args), stdIO()))
+  },
+}
+
+func init() {
+  execCmd.Flags().String("dir", ".", "Project directory whose node_modules/.bin is used")
+  execCmd.Flags().SetInterspersed(false)
+  rootCmd.AddCommand(execCmd)
+}
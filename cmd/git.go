@@ -0,0 +1,277 @@
+package cmd
+
+import (
+  "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "path"
+  "path/filepath"
+  "regexp"
+  "runtime"
+  "strings"
+  "sync"
+)
+
+// Git dependencies: github:user/repo, git+https://, git+ssh://, git+file://
+// and git:// specs, optionally followed by #<commit-ish> or #semver:<range>.
+// A spec resolves to a commit of a bare mirror kept under <cache>/git. The
+// node version is git+<url>#<sha>, which is also how wlim.lock records it,
+// and the packed checkout is stored under <name>/git-<sha>.
+
+// gitSource is a parsed git dependency spec.
+type gitSource struct {
+  URL    string // clone URL
+  Ref    string // commit-ish; empty for the default branch
+  Semver string // tag range from #semver:<range>
+}
+
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// isGitSpec reports whether spec (or a node version) points at a git repo.
+func isGitSpec(spec string) bool {
+  for _, p := range []string{"github:", "git+", "git://"} {
+    if strings.HasPrefix(spec, p) { return true }
+  }
+  return false
+}
+
+func parseGitSpec(spec string) (gitSource, error) {
+  rest, frag, _ := strings.Cut(spec, "#")
+  var g gitSource
+  switch {
+  case strings.HasPrefix(rest, "github:"):
+    repo := strings.TrimSuffix(strings.TrimPrefix(rest, "github:"), ".git")
+    if strings.Count(repo, "/") != 1 || strings.HasPrefix(repo, "/") || strings.HasSuffix(repo, "/") {
+      return g, fmt.Errorf("invalid github spec %q", spec)
+    }
+    g.URL = "https://github.com/" + repo + ".git"
+  case strings.HasPrefix(rest, "git+"):
+    g.URL = strings.TrimPrefix(rest, "git+")
+    // npm accepts git+ssh://git@host:user/repo; git wants the scp-like form
+    if u, ok := strings.CutPrefix(g.URL, "ssh://"); ok {
+      if host, p, ok := strings.Cut(u, ":"); ok && !strings.Contains(host, "/") && p != "" && (p[0] < '0' || p[0] > '9') {
+        g.URL = u
+      }
+    }
+  case strings.HasPrefix(rest, "git://"):
+    g.URL = rest
+  default:
+    return g, fmt.Errorf("not a git spec: %q", spec)
+  }
+  if r, ok := strings.CutPrefix(frag, "semver:"); ok {
+    g.Semver = r
+  } else {
+    g.Ref = frag
+  }
+  return g, nil
+}
+
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+  if dir != "" { args = append([]string{"-C", dir}, args...) }
+  c := exec.CommandContext(ctx, "git", args...)
+  c.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+  var stderr strings.Builder
+  c.Stderr = &stderr
+  out, err := c.Output()
+  if err != nil {
+    return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+  }
+  return string(out), nil
+}
+
+var (
+  gitLocks   keyedMutex
+  gitFetched sync.Map // mirrors already fetched by this process
+)
+
+// gitMirror returns the bare mirror of url, cloning or fetching it at most
+// once per run. Nothing is fetched when the mirror already has the commit
+// want, so installs from wlim.lock work offline.
+func gitMirror(ctx context.Context, url, want string) (string, error) {
+  base, err := cacheBaseDir()
+  if err != nil { return "", err }
+  sum := sha256.Sum256([]byte(url))
+  dir := filepath.Join(base, "git", hex.EncodeToString(sum[:8]))
+  defer gitLocks.lock(dir)()
+  if _, ok := gitFetched.Load(dir); ok { return dir, nil }
+  if _, err := os.Stat(filepath.Join(dir, "HEAD")); err == nil {
+    if want != "" {
+      if _, err := runGit(ctx, dir, "cat-file", "-e", want+"^{commit}"); err == nil { return dir, nil }
+    }
+    logf("Fetching %s\n", url)
+    if _, err := runGit(ctx, dir, "fetch", "--quiet", "--prune", "--tags", "origin"); err != nil { return "", err }
+  } else {
+    logf("Cloning %s\n", url)
+    _ = os.RemoveAll(dir)
+    if err := ensureDir(filepath.Dir(dir)); err != nil { return "", err }
+    if _, err := runGit(ctx, "", "clone", "--quiet", "--mirror", url, dir); err != nil {
+      _ = os.RemoveAll(dir)
+      return "", err
+    }
+  }
+  gitFetched.Store(dir, true)
+  return dir, nil
+}
+
+// gitCommit resolves the commit-ish or semver tag range of g in mirror.
+func gitCommit(ctx context.Context, mirror string, g gitSource) (string, error) {
+  ref := g.Ref
+  if g.Semver != "" {
+    r, err := parseRange(g.Semver)
+    if err != nil { return "", err }
+    out, err := runGit(ctx, mirror, "tag", "--list")
+    if err != nil { return "", err }
+    ref = maxSatisfying(strings.Fields(out), r)
+    if ref == "" { return "", fmt.Errorf("no tag of %s satisfies %q", g.URL, g.Semver) }
+  }
+  if ref == "" { ref = "HEAD" }
+  out, err := runGit(ctx, mirror, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+  if err != nil { return "", fmt.Errorf("%s: unknown ref %q", g.URL, ref) }
+  return strings.TrimSpace(out), nil
+}
+
+// resolveGitDep resolves a git spec to its node version and the metadata of
+// the package.json at that commit.
+func resolveGitDep(ctx context.Context, spec string) (string, *PackageMetadata, error) {
+  g, err := parseGitSpec(spec)
+  if err != nil { return "", nil, err }
+  want := ""
+  if commitSHA.MatchString(g.Ref) { want = g.Ref }
+  mirror, err := gitMirror(ctx, g.URL, want)
+  if err != nil { return "", nil, err }
+  sha, err := gitCommit(ctx, mirror, g)
+  if err != nil { return "", nil, err }
+  out, err := runGit(ctx, mirror, "show", sha+":package.json")
+  if err != nil { return "", nil, fmt.Errorf("%s#%s has no package.json", g.URL, sha) }
+  var md PackageMetadata
+  if err := json.Unmarshal([]byte(out), &md); err != nil { return "", nil, fmt.Errorf("%s#%s: package.json: %w", g.URL, sha, err) }
+  return "git+" + g.URL + "#" + sha, &md, nil
+}
+
+// gitStoreRef is the store directory of a git node ref: git-<sha> plus any
+// peer suffix.
+func gitStoreRef(ref string) string {
+  v := refVersion(ref)
+  _, sha, _ := strings.Cut(v, "#")
+  return "git-" + sha + ref[len(v):]
+}
+
+// fetchGitToStore checks out the commit of version and packs it into dest
+// unprepared; buildDependencies prepares it like it builds other packages.
+func fetchGitToStore(ctx context.Context, version, dest string) error {
+  tmp, err := checkoutGit(ctx, version)
+  if err != nil { return err }
+  defer os.RemoveAll(tmp)
+  return packDir(tmp, dest)
+}
+
+// prepareGitPackage checks out the commit of version again, prepares it and
+// packs the result over the unprepared package in dest.
+func prepareGitPackage(ctx context.Context, version, dest string) error {
+  tmp, err := checkoutGit(ctx, version)
+  if err != nil { return err }
+  defer os.RemoveAll(tmp)
+  if err := prepareCheckout(ctx, tmp); err != nil { return err }
+  return packDir(tmp, dest)
+}
+
+// checkoutGit checks out the commit of version into a new temporary
+// directory, which the caller removes.
+func checkoutGit(ctx context.Context, version string) (string, error) {
+  g, err := parseGitSpec(version)
+  if err != nil { return "", err }
+  mirror, err := gitMirror(ctx, g.URL, g.Ref)
+  if err != nil { return "", err }
+  tmp, err := os.MkdirTemp("", "wlim-git-")
+  if err != nil { return "", err }
+  _, err = runGit(ctx, "", "clone", "--quiet", "--no-checkout", mirror, tmp)
+  if err == nil { _, err = runGit(ctx, tmp, "checkout", "--quiet", g.Ref) }
+  if err != nil {
+    os.RemoveAll(tmp)
+    return "", err
+  }
+  return tmp, nil
+}
+
+// prepareCheckout runs the prepare scripts of a checked out package, after
+// installing its dependencies (dev ones included) as npm does.
+func prepareCheckout(ctx context.Context, dir string) error {
+  pj, err := readPackageJSON(dir)
+  if err != nil { return err }
+  if pj.Scripts["prepare"] == "" { return nil }
+  m, err := readProjectManifest(dir)
+  if err != nil { return err }
+  if deps := m.rootDeps(); len(deps) > 0 {
+    for i := range deps { deps[i].Dir = dir }
+    nodes, roots, _, err := resolveRoots(ctx, deps, make(map[string]*RootDoc), nil)
+    if err != nil { return fmt.Errorf("prepare: %w", err) }
+    storeDir, err := defaultStoreDir()
+    if err != nil { return err }
+    if err := installGraph(ctx, dir, storeDir, roots, nodes, runtime.NumCPU()); err != nil { return fmt.Errorf("prepare: %w", err) }
+  }
+  return runProjectLifecycle(ctx, dir, []string{"preprepare", "prepare", "postprepare"})
+}
+
+// packDir copies the files npm would pack from src to dst: those listed in
+// package.json "files" (plus package.json, README and LICENSE), or all of
+// them, leaving out .git and node_modules either way.
+func packDir(src, dst string) error {
+  pj, err := readPackageJSON(src)
+  if err != nil { return err }
+  var files []string
+  if list, ok := pj.Raw["files"].([]any); ok {
+    for _, f := range list {
+      if s, ok := f.(string); ok { files = append(files, path.Clean(strings.TrimPrefix(s, "./"))) }
+    }
+  }
+  included := func(rel string) bool {
+    if files == nil || (!strings.Contains(rel, "/") && packAlways(rel)) { return true }
+    for _, f := range files {
+      if rel == f || strings.HasPrefix(rel, f+"/") { return true }
+      if ok, _ := path.Match(f, rel); ok { return true }
+    }
+    return false
+  }
+  return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+    if err != nil { return err }
+    rel, err := filepath.Rel(src, p)
+    if err != nil { return err }
+    if rel == "." { return ensureDir(dst) }
+    rel = filepath.ToSlash(rel)
+    if info.Name() == ".git" || info.Name() == "node_modules" {
+      if info.IsDir() { return filepath.SkipDir }
+      return nil
+    }
+    if info.IsDir() || !included(rel) { return nil }
+    out := filepath.Join(dst, filepath.FromSlash(rel))
+    if err := ensureDir(filepath.Dir(out)); err != nil { return err }
+    if info.Mode()&os.ModeSymlink != 0 {
+      target, err := os.Readlink(p)
+      if err != nil { return err }
+      return os.Symlink(target, out)
+    }
+    in, err := os.Open(p)
+    if err != nil { return err }
+    defer in.Close()
+    f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+    if err != nil { return err }
+    if _, err := io.Copy(f, in); err != nil { f.Close(); return err }
+    return f.Close()
+  })
+}
+
+// packAlways reports whether a top-level file is packed regardless of
+// "files".
+func packAlways(name string) bool {
+  lower := strings.ToLower(name)
+  if lower == "package.json" { return true }
+  for _, p := range []string{"readme", "license", "licence"} {
+    if strings.HasPrefix(lower, p) { return true }
+  }
+  return false
+}
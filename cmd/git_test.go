@@ -0,0 +1,108 @@
+package cmd
+
+import (
+  "os"
+  "os/exec"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+)
+
+func TestParseGitSpec(t *testing.T) {
+  cases := []struct {
+    spec string
+    want gitSource
+  }{
+    {"github:acme/lib", gitSource{URL: "https://github.com/acme/lib.git"}},
+    {"github:acme/lib#v2", gitSource{URL: "https://github.com/acme/lib.git", Ref: "v2"}},
+    {"git+https://git.example.com/lib.git#semver:^1.2", gitSource{URL: "https://git.example.com/lib.git", Semver: "^1.2"}},
+    {"git+ssh://git@github.com:acme/lib.git#main", gitSource{URL: "git@github.com:acme/lib.git", Ref: "main"}},
+    {"git+ssh://git@host:2222/lib.git", gitSource{URL: "ssh://git@host:2222/lib.git"}},
+    {"git://host/lib.git", gitSource{URL: "git://host/lib.git"}},
+  }
+  for _, c := range cases {
+    got, err := parseGitSpec(c.spec)
+    if err != nil || got != c.want { t.Errorf("%s: got %+v, %v", c.spec, got, err) }
+  }
+  if _, err := parseGitSpec("github:acme"); err == nil { t.Errorf("github spec without repo should fail") }
+  if name, spec := splitPackageArg("git+https://host/lib.git"); name != "" || spec != "git+https://host/lib.git" { t.Errorf("split: %q %q", name, spec) }
+}
+
+// newTestGitRepo creates a bare repo with a commit per package.json body,
+// tagging each one with its tag, and returns its file:// URL and the shas.
+func newTestGitRepo(t *testing.T, commits ...[2]string) (string, []string) {
+  t.Helper()
+  work, bare := t.TempDir(), filepath.Join(t.TempDir(), "lib.git")
+  git := func(dir string, args ...string) string {
+    c := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+    out, err := c.CombinedOutput()
+    if err != nil { t.Skipf("git %v: %v\n%s", args, err, out) }
+    return strings.TrimSpace(string(out))
+  }
+  git(work, "init", "-q")
+  var shas []string
+  for _, c := range commits {
+    writeTestFile(t, filepath.Join(work, "package.json"), c[0])
+    git(work, "add", "-A")
+    git(work, "commit", "-qm", c[1])
+    git(work, "tag", c[1])
+    shas = append(shas, git(work, "rev-parse", "HEAD"))
+  }
+  git(work, "clone", "-q", "--bare", work, bare)
+  return "file://" + bare, shas
+}
+
+func TestInstallGitDependencies(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("scripts use sh") }
+  newTestRegistry(t, testPkg{Name: "a", Version: "1.0.0"})
+  url, shas := newTestGitRepo(t,
+    [2]string{`{"name":"lib","version":"1.2.0","dependencies":{"a":"1.0.0"}}`, "v1.2.0"},
+    [2]string{`{"name":"lib","version":"1.3.0","files":["built.txt"],"scripts":{"prepare":"echo built > built.txt"}}`, "v1.3.0"},
+  )
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"old":"git+`+url+`#semver:~1.2.0","lib":"git+`+url+`"}}`)
+  writeTestFile(t, filepath.Join(proj, "wlim.json"), `{"onlyBuiltDependencies":["lib"]}`)
+
+  runCLI(t, "install", "--dir", proj)
+  store, _ := defaultStoreDir()
+  oldStore := filepath.Join(store, "old", "git-"+shas[0])
+  if got, _ := os.Readlink(filepath.Join(proj, "node_modules", "old")); got != oldStore { t.Fatalf("old -> %s, want %s", got, oldStore) }
+  if _, err := os.Lstat(filepath.Join(oldStore, "node_modules", "a")); err != nil { t.Fatalf("dependency of git package not linked: %v", err) }
+  libStore := filepath.Join(store, "lib", "git-"+shas[1])
+  if b, err := os.ReadFile(filepath.Join(libStore, "built.txt")); err != nil || string(b) != "built\n" { t.Fatalf("prepare did not run: %q %v", b, err) }
+  if _, err := os.Stat(filepath.Join(libStore, ".git")); !os.IsNotExist(err) { t.Fatalf(".git should not be packed") }
+
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatal(err) }
+  if lp, ok := lf.Packages["lib@git+"+url+"#"+shas[1]]; !ok || lp.Version != "git+"+url+"#"+shas[1] { t.Fatalf("sha not locked: %+v", lf.Packages) }
+
+  // the locked commit is installed again without touching the repo
+  _ = os.RemoveAll(filepath.Join(proj, "node_modules"))
+  _ = os.RemoveAll(store)
+  runCLI(t, "install", "--frozen-lockfile", "--dir", proj)
+  if _, err := os.Stat(filepath.Join(proj, "node_modules", "lib", "built.txt")); err != nil { t.Fatalf("reinstall from lockfile: %v", err) }
+}
+
+func TestInstallGitDependencyPrepareNeedsAllowlist(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("scripts use sh") }
+  newTestRegistry(t)
+  url, _ := newTestGitRepo(t,
+    [2]string{`{"name":"lib","version":"1.3.0","scripts":{"prepare":"echo built > built.txt"}}`, "v1.3.0"},
+  )
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"lib":"git+`+url+`"}}`)
+  built := filepath.Join(proj, "node_modules", "lib", "built.txt")
+
+  runCLI(t, "install", "--dir", proj, "--ignore-scripts")
+  if _, err := os.Stat(built); !os.IsNotExist(err) { t.Fatalf("prepare ran with --ignore-scripts: %v", err) }
+
+  out := captureStdout(t, func() { runCLI(t, "install", "--dir", proj) })
+  if _, err := os.Stat(built); !os.IsNotExist(err) { t.Fatalf("prepare of unlisted git dependency ran: %v", err) }
+  if !strings.Contains(out, "Ignored build scripts of: lib\n") { t.Fatalf("unlisted git dependency not reported:\n%s", out) }
+
+  // the store copy fetched unprepared is prepared once allowed
+  writeTestFile(t, filepath.Join(proj, "wlim.json"), `{"onlyBuiltDependencies":["lib"]}`)
+  runCLI(t, "install", "--dir", proj)
+  if b, err := os.ReadFile(built); err != nil || string(b) != "built\n" { t.Fatalf("prepare did not run once allowed: %q %v", b, err) }
+}
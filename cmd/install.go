@@ -8,6 +8,7 @@ import (
     "errors"
     "fmt"
     "io"
+    "maps"
     "crypto/sha1"
     "crypto/sha512"
     "encoding/base64"
@@ -18,10 +19,10 @@ import (
     "sort"
     "strings"
     "strconv"
+    "sync"
     "time"
     "runtime"
 
-    semver "github.com/Masterminds/semver/v3"
     "github.com/spf13/cobra"
 )
 
@@ -30,6 +31,18 @@ type PackageMetadata struct {
     Name         string            `json:"name"`
     Version      string            `json:"version"`
     Dependencies map[string]string `json:"dependencies"`
+    OptionalDependencies map[string]string `json:"optionalDependencies"`
+    OS           []string          `json:"os,omitempty"`
+    CPU          []string          `json:"cpu,omitempty"`
+    Libc         []string          `json:"libc,omitempty"`
+    Deprecated   deprecation       `json:"deprecated,omitempty"`
+    Engines      engines           `json:"engines,omitempty"`
+    BundleDependencies  json.RawMessage `json:"bundleDependencies,omitempty"`
+    BundledDependencies json.RawMessage `json:"bundledDependencies,omitempty"`
+    PeerDependencies     map[string]string `json:"peerDependencies,omitempty"`
+    PeerDependenciesMeta map[string]struct {
+        Optional bool `json:"optional"`
+    } `json:"peerDependenciesMeta,omitempty"`
     Dist         struct {
         Tarball   string `json:"tarball"`
         Integrity string `json:"integrity"`
@@ -37,10 +50,26 @@ type PackageMetadata struct {
     } `json:"dist"`
 }
 
+// dependencySpecs merges dependencies and optionalDependencies; like npm, an
+// optional entry overrides a regular one with the same name.
+func (md *PackageMetadata) dependencySpecs() map[string]string {
+    if len(md.OptionalDependencies) == 0 { return md.Dependencies }
+    out := make(map[string]string, len(md.Dependencies)+len(md.OptionalDependencies))
+    for k, v := range md.Dependencies { out[k] = v }
+    for k, v := range md.OptionalDependencies { out[k] = v }
+    return out
+}
+
+func (md *PackageMetadata) isOptionalDep(name string) bool {
+    _, ok := md.OptionalDependencies[name]
+    return ok
+}
+
 // 루트 문서(전체 버전들을 포함)
 type RootDoc struct {
     DistTags map[string]string             `json:"dist-tags"`
     Versions map[string]PackageMetadata    `json:"versions"`
+    Time     map[string]string             `json:"time,omitempty"` // version -> publish time
 }
 
 var httpClient = &http.Client{Timeout: 20 * time.Second}
@@ -165,7 +194,8 @@ func fetchRootDoc(ctx context.Context, packageName string, cache map[string]*Roo
         }
     }
     // disk cache with TTL
-    if useCache, rd := tryReadRootDocCacheWithTTL(packageName); useCache && rd != nil {
+    // docs cached without publish times cannot honor a release cutoff
+    if useCache, rd := tryReadRootDocCacheWithTTL(packageName); useCache && rd != nil && (rd.Time != nil || releaseCutoff.IsZero()) {
         if cache != nil { cache[packageName] = rd }
         return rd, nil
     }
@@ -189,51 +219,57 @@ func resolveVersionAndMetadata(ctx context.Context, name, spec string, cache map
         return "", nil, err
     }
 
-    // 1) latest 또는 빈값
-    if spec == "" || spec == "latest" {
-        v := rd.DistTags["latest"]
-        if v == "" {
-            return "", nil, fmt.Errorf("no latest dist-tag for %s", name)
-        }
+    // 1) dist-tag (빈값은 latest)
+    tag := spec
+    if tag == "" {
+        tag = "latest"
+    }
+    if v, ok := rd.DistTags[tag]; ok {
         md, ok := rd.Versions[v]
         if !ok {
             return "", nil, fmt.Errorf("version %s not found for %s", v, name)
         }
-        return v, &md, nil
+        if rd.released(v) {
+            return v, &md, nil
+        }
+        // cutoff 이후의 latest는 그 전의 최고 버전으로 대체
+        if tag != "latest" {
+            return "", nil, fmt.Errorf("%s@%s (%s) was published after %s", name, tag, v, cutoffString())
+        }
+        spec = ""
+    } else if tag == "latest" {
+        return "", nil, fmt.Errorf("no latest dist-tag for %s", name)
     }
 
     // 2) 정확한 버전 존재 시
     if md, ok := rd.Versions[spec]; ok {
+        if !rd.released(spec) {
+            return "", nil, fmt.Errorf("%s@%s was published after %s", name, spec, cutoffString())
+        }
         v := spec
         copy := md
         return v, &copy, nil
     }
 
-    // 3) semver 제약 조건으로 해석
-    cons, err := semver.NewConstraint(spec)
+    // 3) npm 범위로 해석: latest가 범위를 만족하면 우선, 아니면 최대 만족 버전
+    rng, err := parseRange(spec)
     if err != nil {
         return "", nil, fmt.Errorf("invalid version spec %q for %s: %w", spec, name, err)
     }
-
-    // 가능한 버전 수집 후 정렬하여 최대 만족 버전 선택
-    var versions semver.Collection
-    versionMap := make(map[string]PackageMetadata, len(rd.Versions))
-    for vStr, md := range rd.Versions {
-        v, err := semver.NewVersion(vStr)
-        if err != nil {
-            continue
-        }
-        if cons.Check(v) {
-            versions = append(versions, v)
-            versionMap[v.Original()] = md
-        }
+    chosen := ""
+    if latest, err := parseVersion(rd.DistTags["latest"]); err == nil && rng.test(latest) && rd.released(rd.DistTags["latest"]) {
+        chosen = rd.DistTags["latest"]
+    }
+    if _, ok := rd.Versions[chosen]; !ok {
+        chosen = maxSatisfying(rd.releasedVersions(), rng)
     }
-    if len(versions) == 0 {
+    if chosen == "" && !releaseCutoff.IsZero() {
+        return "", nil, fmt.Errorf("no versions of %s published before %s satisfy %q", name, cutoffString(), spec)
+    }
+    if chosen == "" {
         return "", nil, fmt.Errorf("no versions of %s satisfy %q", name, spec)
     }
-    sort.Sort(versions)
-    chosen := versions[len(versions)-1].Original()
-    md := versionMap[chosen]
+    md := rd.Versions[chosen]
     return chosen, &md, nil
 }
 
@@ -343,8 +379,50 @@ func defaultStoreDir() (string, error) {
 
 // store path for a given package@version (no integrity yet)
 func storePkgPath(storeDir, name, version string) string {
-    // For simplicity: ~/.wlim/store/v3/<name>/<version>
-    return filepath.Join(storeDir, name, version)
+    // For simplicity: ~/.wlim/store/v3/<name>/<version>; peer instances use
+    // <version>(peer@version) with scoped peer names flattened. Aliases are
+    // stored under the real package, git dependencies under git-<sha> and
+    // file: dependencies under file-<hash>, tarball URLs under url-<hash>.
+    // link: dependencies stay where they are.
+    if real, ref, ok := aliasTarget(version); ok { name, version = real, ref }
+    if isGitSpec(version) { version = gitStoreRef(version) }
+    if isTarballURL(version) { version = tarballStoreRef(version) }
+    if p, ok := strings.CutPrefix(refVersion(version), linkPrefix); ok { return p }
+    if strings.HasPrefix(version, filePrefix) { version = localStoreRef(version) }
+    return filepath.Join(storeDir, name, strings.ReplaceAll(version, "/", "+"))
+}
+
+// cloneStoreEntry populates a peer instance from the extracted package by
+// hard-linking its files (copying where links are not possible). The
+// dependency links in the source's node_modules are not carried over, its
+// bundled dependencies are.
+func cloneStoreEntry(src, dst string) error {
+    return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        rel, err := filepath.Rel(src, path)
+        if err != nil { return err }
+        if rel == "." { return ensureDir(dst) }
+        if rel == "pkg.tgz" || (isDepLink(rel) && info.Mode()&os.ModeSymlink != 0) {
+            return nil
+        }
+        out := filepath.Join(dst, rel)
+        switch {
+        case info.IsDir():
+            return ensureDir(out)
+        case info.Mode()&os.ModeSymlink != 0:
+            target, err := os.Readlink(path)
+            if err != nil { return err }
+            return os.Symlink(target, out)
+        }
+        if err := os.Link(path, out); err == nil { return nil }
+        in, err := os.Open(path)
+        if err != nil { return err }
+        defer in.Close()
+        f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+        if err != nil { return err }
+        if _, err := io.Copy(f, in); err != nil { f.Close(); return err }
+        return f.Close()
+    })
 }
 
 // ensure a symlink, replacing existing file/dir if necessary
@@ -403,8 +481,11 @@ func linkIntoNodeModules(baseNodeModules, pkgName, target string) error {
 
 // read package.json to discover bin entries
 type pkgJSON struct {
-    Name string
-    Bin  map[string]string
+    Name    string
+    Version string
+    Bin     map[string]string
+    Scripts map[string]string
+    Raw     map[string]any // whole document, for npm_package_* script env
 }
 
 func readPackageJSON(dir string) (*pkgJSON, error) {
@@ -417,8 +498,14 @@ func readPackageJSON(dir string) (*pkgJSON, error) {
     if err := json.NewDecoder(f).Decode(&raw); err != nil {
         return nil, err
     }
-    pj := &pkgJSON{Bin: make(map[string]string)}
+    pj := &pkgJSON{Bin: make(map[string]string), Scripts: make(map[string]string), Raw: raw}
     if n, ok := raw["name"].(string); ok { pj.Name = n }
+    if v, ok := raw["version"].(string); ok { pj.Version = v }
+    if sc, ok := raw["scripts"].(map[string]any); ok {
+        for k, v := range sc {
+            if s, ok := v.(string); ok { pj.Scripts[k] = s }
+        }
+    }
     if b, ok := raw["bin"]; ok {
         switch v := b.(type) {
         case string:
@@ -465,6 +552,7 @@ func resolveAndInstall(ctx context.Context, packageName, versionSpec, projectDir
 
     // 먼저 의존성 처리
     for depName, depSpec := range md.Dependencies {
+        if md.isBundled(depName) { continue }
         if err := resolveAndInstall(ctx, depName, depSpec, projectDir, installed, rootCache); err != nil {
             return err
         }
@@ -493,6 +581,7 @@ func resolveAndInstall(ctx context.Context, packageName, versionSpec, projectDir
         return err
     }
     for depName := range md.Dependencies {
+        if md.isBundled(depName) { continue }
         // Resolve installed version to compute store path
         depV, _, err := resolveVersionAndMetadata(ctx, depName, md.Dependencies[depName], rootCache)
         if err != nil {
@@ -527,20 +616,73 @@ type GraphNode struct {
     Version string
     MD   *PackageMetadata
     Deps map[string]string // depName -> resolvedVersion
+    Peers map[string]string // peer name -> resolved reference, set for peer instances
+    Optional bool   // only reachable through optionalDependencies
+    Skipped  string // why an optional node was not installed
+    Unsupported bool // os/cpu/libc do not match the install target
 }
 
+// ref is how dependents refer to n: its version plus any peer suffix. It is
+// also the store directory name of the instance.
+func (n *GraphNode) ref() string { return n.Version + peerSuffix(n.Peers) }
+
+func (n *GraphNode) key() string { return keyOf(n.Name, n.ref()) }
+
+// skip reports whether installGraph left n out of node_modules.
+func (n *GraphNode) skip() bool { return n.Skipped != "" || n.Unsupported }
+
 func keyOf(name, version string) string { return name + "@" + version }
 
-func resolveGraph(ctx context.Context, rootName, rootSpec string, cache map[string]*RootDoc) (map[string]*GraphNode, *GraphNode, error) {
+// splitKey splits a lockfile key (name@ref, possibly scoped and with a peer
+// suffix) into name and ref.
+func splitKey(key string) (string, string) {
+    if len(key) > 1 {
+        if at := strings.Index(key[1:], "@"); at >= 0 { return key[:at+1], key[at+2:] }
+    }
+    return key, ""
+}
+
+// reachableNodes returns the subset of nodes reachable from roots.
+func reachableNodes(roots []*GraphNode, nodes map[string]*GraphNode) map[string]*GraphNode {
+    out := make(map[string]*GraphNode)
+    var queue []*GraphNode
+    queue = append(queue, roots...)
+    for len(queue) > 0 {
+        n := queue[0]
+        queue = queue[1:]
+        k := n.key()
+        if _, seen := out[k]; seen { continue }
+        out[k] = n
+        for depName, depV := range n.Deps {
+            if d, ok := nodes[keyOf(depName, depV)]; ok { queue = append(queue, d) }
+        }
+    }
+    return out
+}
+
+// resolveGraph resolves rootSpec and its dependencies through res (a fresh
+// resolver when nil). Requirements that cannot be resolved are collected
+// with the chain leading to them and returned as a *resolveError.
+func resolveGraph(ctx context.Context, rootName, rootSpec string, cache map[string]*RootDoc, res *resolver) (map[string]*GraphNode, *GraphNode, error) {
+    if res == nil { res = newResolver(nil) }
     // BFS/DFS hybrid with explicit queue of resolved nodes
     nodes := make(map[string]*GraphNode)
     // resolve root first
-    v, md, err := resolveVersionAndMetadata(ctx, rootName, rootSpec, cache)
-    if err != nil { return nil, nil, err }
+    rootPath := []string{keyOf(rootName, rootSpec)}
+    v, md, err := res.resolve(ctx, rootName, rootSpec, rootPath, cache)
+    if err != nil {
+        failed := &resolveError{}
+        failed.add(err, rootPath)
+        return nil, nil, failed
+    }
     root := &GraphNode{Name: rootName, Version: v, MD: md, Deps: make(map[string]string)}
     nodes[keyOf(root.Name, root.Version)] = root
+    // paths holds the chain of dependents each node was first reached by
+    paths := map[string][]string{root.key(): {root.key()}}
+    res.noteDeprecated(root, paths[root.key()])
     type pair struct { name, version string }
     queue := []pair{{rootName, v}}
+    failed := &resolveError{}
 
     for len(queue) > 0 {
         cur := queue[0]
@@ -548,20 +690,131 @@ func resolveGraph(ctx context.Context, rootName, rootSpec string, cache map[stri
         curKey := keyOf(cur.name, cur.version)
         node := nodes[curKey]
         // compute deps resolved versions and enqueue
-        for depName, spec := range node.MD.Dependencies {
-            dv, dmd, err := resolveVersionAndMetadata(ctx, depName, spec, cache)
-            if err != nil { return nil, nil, err }
+        specs := node.MD.dependencySpecs()
+        depNames := make([]string, 0, len(specs))
+        for depName := range specs { depNames = append(depNames, depName) }
+        sort.Strings(depNames)
+        for _, depName := range depNames {
+            // bundled dependencies come with the package
+            if node.MD.isBundled(depName) { continue }
+            spec := specs[depName]
+            dv, dmd, reqPath, err := res.resolveEdge(ctx, paths[curKey], depName, spec, localDir(node.Version), cache)
+            if err != nil {
+                if node.MD.isOptionalDep(depName) {
+                    fmt.Printf("Warning: skipping optional dependency %s@%s of %s: %v\n", depName, spec, strings.Join(paths[curKey], " > "), err)
+                    continue
+                }
+                failed.add(err, reqPath)
+                continue
+            }
             node.Deps[depName] = dv
             dkey := keyOf(depName, dv)
             if _, ok := nodes[dkey]; !ok {
                 nodes[dkey] = &GraphNode{Name: depName, Version: dv, MD: dmd, Deps: make(map[string]string)}
+                paths[dkey] = appendPath(paths[curKey], dkey)
+                res.noteDeprecated(nodes[dkey], paths[dkey])
                 queue = append(queue, pair{depName, dv})
             }
         }
     }
+    if len(failed.conflicts) > 0 { return nil, nil, failed }
     return nodes, root, nil
 }
 
+// markOptional flags every node that is only reachable from optional roots
+// or through optionalDependencies edges.
+func markOptional(roots []*GraphNode, nodes map[string]*GraphNode, rootSpecs map[string]LockRoot) {
+    required := make(map[string]bool)
+    var queue []*GraphNode
+    for _, r := range roots {
+        if rootSpecs[r.Name].Section != sectionOptional { queue = append(queue, r) }
+    }
+    for len(queue) > 0 {
+        n := queue[0]
+        queue = queue[1:]
+        k := n.key()
+        if required[k] { continue }
+        required[k] = true
+        for depName, depV := range n.Deps {
+            if n.MD != nil && n.MD.isOptionalDep(depName) { continue }
+            if d, ok := nodes[keyOf(depName, depV)]; ok { queue = append(queue, d) }
+        }
+    }
+    for k, n := range nodes { n.Optional = !required[k] }
+}
+
+// splitPackageArg splits a command line argument like name@spec,
+// @scope/name@spec or alias@npm:name@spec; the spec defaults to latest. A
+// bare git, local or tarball URL spec has no name yet; resolveRoots takes it
+// from package.json.
+func splitPackageArg(arg string) (string, string) {
+    if isSourceSpec(arg) { return "", arg }
+    name, spec := splitKey(arg)
+    if spec == "" { spec = "latest" }
+    return name, spec
+}
+
+// resolveRoots resolves the graph of every root and merges the node sets,
+// sharing res (a fresh resolver when nil) so roots settle on the same
+// versions. Incompatible peers are backtracked out of by pinning other
+// versions where possible. It also returns the root specs to record in the
+// lockfile.
+func resolveRoots(ctx context.Context, deps []rootDep, cache map[string]*RootDoc, res *resolver) (map[string]*GraphNode, []*GraphNode, map[string]LockRoot, error) {
+    if res == nil { res = newResolver(nil) }
+    restore := res.snapshot()
+    for attempt := 0; ; attempt++ {
+        base, roots, rootSpecs, err := resolveRootGraphs(ctx, deps, cache, res)
+        if err != nil { return nil, nil, nil, err }
+        allNodes, roots, issues, err := resolvePeers(ctx, roots, base, cache, res)
+        if err != nil { return nil, nil, nil, err }
+        if attempt < maxBacktracks && res.backtrack(ctx, issues, base, cache) {
+            restore()
+            continue
+        }
+        printPeerIssues(issues)
+        res.printDeprecations()
+        markOptional(roots, allNodes, rootSpecs)
+        return allNodes, roots, rootSpecs, nil
+    }
+}
+
+// resolveRootGraphs resolves the graph of every root, before peers. The
+// failures of all required roots are reported together.
+func resolveRootGraphs(ctx context.Context, deps []rootDep, cache map[string]*RootDoc, res *resolver) (map[string]*GraphNode, []*GraphNode, map[string]LockRoot, error) {
+    allNodes := make(map[string]*GraphNode)
+    var roots []*GraphNode
+    rootSpecs := make(map[string]LockRoot, len(deps))
+    failed := &resolveError{}
+    for _, d := range deps {
+        if d.Link != "" {
+            // workspace packages are linked, not resolved
+            rootSpecs[d.Name] = LockRoot{Spec: d.Spec, Section: d.Section, Link: d.Link}
+            continue
+        }
+        spec := absLocalSpec(d.Spec, d.Dir)
+        if d.Name == "" {
+            _, md, err := resolveDep(ctx, d.Name, spec, cache)
+            if err != nil { return nil, nil, nil, err }
+            if md.Name == "" { return nil, nil, nil, fmt.Errorf("%s: package.json has no name", d.Spec) }
+            d.Name = md.Name
+        }
+        nodes, root, err := resolveGraph(ctx, d.Name, spec, cache, res)
+        if err != nil {
+            if d.Section == sectionOptional {
+                fmt.Printf("Warning: skipping optional dependency %s@%s: %v\n", d.Name, d.Spec, err)
+                continue
+            }
+            if failed.merge(err) { continue }
+            return nil, nil, nil, fmt.Errorf("%s: %w", d.Section, err)
+        }
+        for k, n := range nodes { allNodes[k] = n }
+        roots = append(roots, root)
+        rootSpecs[d.Name] = LockRoot{Spec: d.Spec, Section: d.Section}
+    }
+    if len(failed.conflicts) > 0 { return nil, nil, nil, failed }
+    return allNodes, roots, rootSpecs, nil
+}
+
 func parseSRI(integrity string) (algo string, sum []byte, ok bool) {
     if integrity == "" { return "", nil, false }
     // pick first sha512 entry if present
@@ -654,21 +907,79 @@ func downloadToFileWithRetry(ctx context.Context, url, outPath string, attempts
 type LockPackage struct {
     Name string `json:"name"`
     Version string `json:"version"`
+    PackageVersion string `json:"packageVersion,omitempty"` // package.json version of a tarball URL package
+    Alias string `json:"alias,omitempty"` // name the package is linked as, for npm: alias specs
     Dependencies map[string]string `json:"dependencies"`
+    Peers map[string]string `json:"peers,omitempty"` // resolved peers of this instance (the key's suffix)
+    Optional bool `json:"optional,omitempty"`
+    Skipped string `json:"skipped,omitempty"` // failure that made the last install skip this optional package
+    Dev bool `json:"dev,omitempty"` // only reached from devDependencies
+    Peer bool `json:"peer,omitempty"` // only reached through peer dependencies
+    OS []string `json:"os,omitempty"`
+    CPU []string `json:"cpu,omitempty"`
+    Libc []string `json:"libc,omitempty"`
+    Engines map[string]string `json:"engines,omitempty"`
+    Resolved string `json:"resolved,omitempty"` // tarball URL
+    Integrity string `json:"integrity,omitempty"` // SRI of the tarball, checked on every download
+    Deprecated string `json:"deprecated,omitempty"` // deprecation message of the version
+    Bundled []string `json:"bundled,omitempty"` // dependencies shipped inside the tarball, not resolved
+}
+// LockRoot records the spec and package.json section a root was requested with.
+type LockRoot struct {
+    Spec    string `json:"spec"`
+    Section string `json:"section"`
+    Link    string `json:"link,omitempty"` // path to the workspace package linked instead of a registry root
+}
+// LockImporter holds the roots of one workspace package.
+type LockImporter struct {
+    Roots []string `json:"roots"`
+    RootSpecs map[string]LockRoot `json:"rootSpecs,omitempty"`
 }
 type LockFile struct {
+    LockfileVersion int `json:"lockfileVersion"`
     Roots []string `json:"roots"`
+    RootSpecs map[string]LockRoot `json:"rootSpecs,omitempty"` // root name -> how it was requested
+    Importers map[string]LockImporter `json:"importers,omitempty"` // workspace dir -> its roots
+    Overrides map[string]string `json:"overrides,omitempty"` // flattened overrides/resolutions the graph was resolved with
     Packages map[string]LockPackage `json:"packages"`
 }
 
-func writeLockfile(projectDir string, roots []*GraphNode, nodes map[string]*GraphNode) error {
-    lf := LockFile{Packages: make(map[string]LockPackage)}
+func writeLockfile(projectDir string, roots []*GraphNode, nodes map[string]*GraphNode, rootSpecs map[string]LockRoot) error {
+    return saveLockfile(projectDir, newLockFile(roots, nodes, rootSpecs))
+}
+
+// newLockFile records the graph of the root project.
+func newLockFile(roots []*GraphNode, nodes map[string]*GraphNode, rootSpecs map[string]LockRoot) *LockFile {
+    lf := &LockFile{RootSpecs: rootSpecs, Packages: make(map[string]LockPackage)}
     for _, r := range roots {
-        lf.Roots = append(lf.Roots, keyOf(r.Name, r.Version))
+        lf.Roots = append(lf.Roots, r.key())
     }
     for k, n := range nodes {
-        lf.Packages[k] = LockPackage{Name: n.Name, Version: n.Version, Dependencies: n.Deps}
+        lp := LockPackage{Name: n.Name, Version: n.Version, Dependencies: n.Deps, Peers: n.Peers, Optional: n.Optional, Skipped: n.Skipped}
+        if real, v, ok := aliasTarget(n.Version); ok { lp.Name, lp.Version, lp.Alias = real, v, n.Name }
+        if n.MD != nil {
+            lp.OS, lp.CPU, lp.Libc, lp.Engines = n.MD.OS, n.MD.CPU, n.MD.Libc, n.MD.Engines
+            lp.Deprecated, lp.Bundled = string(n.MD.Deprecated), n.MD.bundled()
+            // git and local packages are read from their source
+            if !isGitSpec(n.Version) && !isLocalSpec(n.Version) { lp.Resolved, lp.Integrity = n.MD.Dist.Tarball, lockIntegrity(n.MD) }
+            if isTarballURL(n.Version) { lp.PackageVersion = n.MD.Version }
+        }
+        lf.Packages[k] = lp
     }
+    return lf
+}
+
+func saveLockfile(projectDir string, lf *LockFile) error {
+    lf.LockfileVersion = lockfileVersion
+    lf.RootSpecs = completeRootSpecs(lf.Roots, lf.RootSpecs)
+    for dir, li := range lf.Importers {
+        li.RootSpecs = completeRootSpecs(li.Roots, li.RootSpecs)
+        lf.Importers[dir] = li
+    }
+    markLockTypes(lf)
+    disk := *lf
+    localizeLockfile(&disk, projectDir, true)
+    lf = &disk
     path := filepath.Join(projectDir, "wlim.lock")
     f, err := os.Create(path)
     if err != nil { return err }
@@ -683,10 +994,25 @@ func readLockfile(projectDir string) (*LockFile, error) {
     if err != nil { return nil, err }
     var lf LockFile
     if err := json.Unmarshal(b, &lf); err != nil { return nil, err }
+    if err := migrateLockfile(&lf); err != nil { return nil, err }
+    localizeLockfile(&lf, projectDir, false)
+    rememberTarballIntegrity(&lf)
     return &lf, nil
 }
 
 func metadataForExactVersion(ctx context.Context, name, version string, cache map[string]*RootDoc) (*PackageMetadata, error) {
+    if isGitSpec(version) {
+        _, md, err := resolveGitDep(ctx, version)
+        return md, err
+    }
+    if isLocalSpec(version) {
+        _, md, err := resolveLocal(version)
+        return md, err
+    }
+    if isTarballURL(version) {
+        _, md, err := resolveTarballURL(ctx, version)
+        return md, err
+    }
     rd, err := fetchRootDoc(ctx, name, cache)
     if err != nil { return nil, err }
     md, ok := rd.Versions[version]
@@ -699,92 +1025,130 @@ func metadataForExactVersion(ctx context.Context, name, version string, cache ma
 func nodesFromLockfile(ctx context.Context, projectDir string, cache map[string]*RootDoc) (map[string]*GraphNode, []*GraphNode, error) {
     lf, err := readLockfile(projectDir)
     if err != nil { return nil, nil, err }
+    return nodesFromLock(ctx, lf, cache)
+}
+
+// nodesFromLock builds nodes and roots from an already parsed lockfile.
+// Registry packages with resolved and integrity need no packument.
+func nodesFromLock(ctx context.Context, lf *LockFile, cache map[string]*RootDoc) (map[string]*GraphNode, []*GraphNode, error) {
     nodes := make(map[string]*GraphNode)
     for key, lp := range lf.Packages {
-        md, err := metadataForExactVersion(ctx, lp.Name, lp.Version, cache)
-        if err != nil { return nil, nil, err }
-        nodes[key] = &GraphNode{Name: lp.Name, Version: lp.Version, MD: md, Deps: lp.Dependencies}
+        md, err := lp.metadata(), error(nil)
+        if md == nil { md, err = metadataForExactVersion(ctx, lp.Name, lp.Version, cache) }
+        name, version := lp.Name, lp.Version
+        if lp.Alias != "" { name, version = lp.Alias, aliasPrefix+keyOf(lp.Name, lp.Version) }
+        skipped := ""
+        if err != nil {
+            if !lp.Optional { return nil, nil, err }
+            fmt.Printf("Warning: skipping optional dependency %s: %v\n", key, err)
+            md, skipped = &PackageMetadata{Name: lp.Name, Version: lp.Version}, err.Error()
+        }
+        nodes[key] = &GraphNode{Name: name, Version: version, MD: md, Deps: lp.Dependencies, Peers: lp.Peers, Optional: lp.Optional, Skipped: skipped}
     }
     var roots []*GraphNode
     for _, r := range lf.Roots {
         if n, ok := nodes[r]; ok { roots = append(roots, n) }
     }
-    if len(roots) == 0 { return nil, nil, fmt.Errorf("no roots in lockfile") }
+    if len(roots) == 0 && len(lf.RootSpecs) == 0 && len(lf.Importers) == 0 { return nil, nil, fmt.Errorf("no roots in lockfile") }
     return nodes, roots, nil
 }
 
 // updateLockfile re-resolves the specified roots (or all, if names empty) to latest
-// and writes a new lockfile capturing the new graph.
-func updateLockfile(ctx context.Context, projectDir string, names []string, cache map[string]*RootDoc, policy string, specs map[string]string) error {
+// and writes a new lockfile capturing the new graph. A non-empty tag follows
+// that dist-tag instead (latest for packages without it). Workspace packages
+// are updated the same way as the root project.
+func updateLockfile(ctx context.Context, projectDir string, names []string, cache map[string]*RootDoc, policy, tag string, specs map[string]string) error {
     lf, err := readLockfile(projectDir)
     if err != nil { return err }
     // Determine roots to update
     rootsToUpdate := make(map[string]bool)
-    if len(names) == 0 {
-        for _, r := range lf.Roots { // r is like name@version
-            if at := strings.LastIndex(r, "@"); at > 0 { rootsToUpdate[r[:at]] = true }
-        }
-    } else {
-        for _, n := range names { rootsToUpdate[n] = true }
-    }
+    for _, n := range names { rootsToUpdate[n] = true }
+    // updated roots are not held back by the old lockfile, only kept
+    // consistent with each other
+    res := newResolver(nil)
+    if res.overrides, err = parseOverrides(lf.Overrides, projectDir); err != nil { return err }
     // Resolve new graphs for those roots based on explicit specs or policy
-    allNodes := make(map[string]*GraphNode)
-    var roots []*GraphNode
-    for _, r := range lf.Roots {
-        name := r
-        if at := strings.LastIndex(r, "@"); at > 0 { name = r[:at] }
-        spec := "latest"
-        if !rootsToUpdate[name] {
-            // keep existing version spec from lockfile
-            ver := r[strings.LastIndex(r, "@")+1:]
-            spec = ver
-        } else if s, ok := specs[name]; ok && s != "" {
-            spec = s
-        } else if policy == "minor" || policy == "patch" {
-            // compute next version per policy vs current
-            curVer := r[strings.LastIndex(r, "@")+1:]
-            nv, err := nextVersionForPolicy(ctx, name, curVer, policy, cache)
-            if err == nil && nv != "" {
-                spec = nv
-            } else {
-                spec = "latest"
+    relock := func(rootKeys []string, rootSpecs map[string]LockRoot) (map[string]*GraphNode, []*GraphNode, error) {
+        var deps []rootDep
+        for _, r := range rootKeys {
+            name, ref := splitKey(r)
+            spec := "latest"
+            // aliases are updated through the real package
+            pkg, curVer := name, refVersion(ref)
+            real, realVer, isAlias := aliasTarget(curVer)
+            if isAlias { pkg, curVer = real, realVer }
+            if len(names) > 0 && !rootsToUpdate[name] {
+                // keep existing version spec from lockfile
+                spec = curVer
+            } else if s, ok := specs[name]; ok && s != "" {
+                spec = s
+            } else if isSourceSpec(curVer) {
+                // git roots follow the ref they were requested with, local
+                // and tarball ones are read again
+                spec = curVer
+                if s := rootSpecs[name].Spec; isGitSpec(s) { spec = s }
+            } else if tag != "" {
+                if rd, err := fetchRootDoc(ctx, pkg, cache); err == nil && rd.DistTags[tag] != "" { spec = tag }
+            } else if policy == "minor" || policy == "patch" {
+                // compute next version per policy vs current
+                nv, err := nextVersionForPolicy(ctx, pkg, curVer, policy, cache)
+                if err == nil && nv != "" {
+                    spec = nv
+                } else {
+                    spec = "latest"
+                }
             }
+            if _, _, explicit := parseAlias(spec); isAlias && !explicit { spec = aliasPrefix + keyOf(pkg, spec) }
+            section := rootSpecs[name].Section
+            if section == "" { section = sectionProd }
+            deps = append(deps, rootDep{Name: name, Spec: spec, Section: section})
         }
-        nodes, root, err := resolveGraph(ctx, name, spec, cache)
+        allNodes, roots, _, err := resolveRoots(ctx, deps, cache, res)
+        return allNodes, roots, err
+    }
+    importers := []*importer{{Dir: ".", RootSpecs: lf.RootSpecs}}
+    rootKeys := [][]string{lf.Roots}
+    for _, d := range sortedImporterDirs(lf) {
+        importers = append(importers, &importer{Dir: d, RootSpecs: lf.Importers[d].RootSpecs})
+        rootKeys = append(rootKeys, lf.Importers[d].Roots)
+    }
+    all := make(map[string]*GraphNode)
+    for i, imp := range importers {
+        nodes, roots, err := relock(rootKeys[i], imp.RootSpecs)
         if err != nil { return err }
-        for k, n := range nodes { allNodes[k] = n }
-        roots = append(roots, root)
+        imp.Roots = mergeNodes(all, nodes, roots)
     }
-    return writeLockfile(projectDir, roots, allNodes)
+    return writeImportersLockfile(projectDir, importers, all, lf.Overrides)
 }
 
 // nextVersionForPolicy picks the highest version according to policy compared to current
 func nextVersionForPolicy(ctx context.Context, name, current, policy string, cache map[string]*RootDoc) (string, error) {
     rd, err := fetchRootDoc(ctx, name, cache)
     if err != nil { return "", err }
-    cur, err := semver.NewVersion(current)
+    cur, err := parseVersion(current)
     if err != nil { return "", err }
-    var best *semver.Version
+    var best *npmVersion
+    chosen := current
     for vStr := range rd.Versions {
-        v, err := semver.NewVersion(vStr)
-        if err != nil { continue }
-        // Must be >= current
-        if v.LessThan(cur) || v.Equal(cur) { continue }
+        v, err := parseVersion(vStr)
+        if err != nil || !rd.released(vStr) { continue }
+        // Must be > current
+        if v.compare(cur) <= 0 { continue }
+        // prereleases only as updates of a prerelease of the same version
+        if len(v.Pre) > 0 && !(len(cur.Pre) > 0 && v.sameTuple(cur)) { continue }
         switch policy {
         case "patch":
-            if v.Major() != cur.Major() || v.Minor() != cur.Minor() { continue }
+            if v.Major != cur.Major || v.Minor != cur.Minor { continue }
         case "minor":
-            if v.Major() != cur.Major() { continue }
+            if v.Major != cur.Major { continue }
         default:
             // latest: no additional constraint
         }
-        if best == nil || best.LessThan(v) {
-            vv := v
-            best = vv
+        if best == nil || best.compare(v) < 0 {
+            best, chosen = v, vStr
         }
     }
-    if best == nil { return current, nil }
-    return best.Original(), nil
+    return chosen, nil
 }
 
 // cleanStore removes store entries not present in the current lockfile.
@@ -792,26 +1156,27 @@ func cleanStore(projectDir, storeDir string, dryRun bool) error {
     lf, err := readLockfile(projectDir)
     if err != nil { return err }
     referenced := make(map[string]bool)
-    for k := range lf.Packages { // keys are name@version
-        parts := strings.SplitN(k, "@", 2)
-        if len(parts) != 2 { continue }
-        referenced[filepath.Join(storeDir, parts[0], parts[1])] = true
+    for k := range lf.Packages { // keys are name@version[(peers)]
+        name, ref := splitKey(k)
+        referenced[storePkgPath(storeDir, name, ref)] = true
+        // peer instances are cloned from the plain extraction
+        referenced[storePkgPath(storeDir, name, refVersion(ref))] = true
     }
     // Walk storeDir
     return filepath.Walk(storeDir, func(path string, info os.FileInfo, err error) error {
         if err != nil { return err }
         if !info.IsDir() { return nil }
-        // Only act on directories at depth >=2
         rel, err := filepath.Rel(storeDir, path)
         if err != nil || rel == "." { return nil }
+        // entries live at <name>/<version> or @scope/<name>/<version>
         parts := strings.Split(rel, string(os.PathSeparator))
-        if len(parts) != 2 { return nil }
-        if !referenced[path] {
-            if !dryRun {
-                return os.RemoveAll(path)
-            }
+        depth := 2
+        if strings.HasPrefix(parts[0], "@") { depth = 3 }
+        if len(parts) < depth { return nil }
+        if !referenced[path] && !dryRun {
+            if err := os.RemoveAll(path); err != nil { return err }
         }
-        return nil
+        return filepath.SkipDir
     })
 }
 
@@ -831,8 +1196,7 @@ func removeFromLockfile(projectDir string, names []string) error {
     for _, n := range names { toRemove[n] = true }
     var keptRoots []string
     for _, r := range lf.Roots {
-        name := r
-        if at := strings.LastIndex(r, "@"); at > 0 { name = r[:at] }
+        name, _ := splitKey(r)
         if !toRemove[name] { keptRoots = append(keptRoots, r) }
     }
     lf.Roots = keptRoots
@@ -847,9 +1211,10 @@ func removeFromLockfile(projectDir string, names []string) error {
         }
         adj[key] = deps
     }
-    // seed queue with kept roots
+    // seed queue with kept roots and the roots of workspace packages
     queue := []string{}
     for _, r := range lf.Roots { queue = append(queue, r) }
+    for _, imp := range lf.Importers { queue = append(queue, imp.Roots...) }
     for len(queue) > 0 {
         k := queue[0]
         queue = queue[1:]
@@ -863,14 +1228,8 @@ func removeFromLockfile(projectDir string, names []string) error {
         if reachable[k] { keptPkgs[k] = v }
     }
     lf.Packages = keptPkgs
-    // write back
-    path := filepath.Join(projectDir, "wlim.lock")
-    f, err := os.Create(path)
-    if err != nil { return err }
-    defer f.Close()
-    enc := json.NewEncoder(f)
-    enc.SetIndent("", "  ")
-    return enc.Encode(lf)
+    for name := range toRemove { delete(lf.RootSpecs, name) }
+    return saveLockfile(projectDir, lf)
 }
 
 func installParallel(ctx context.Context, projectDir, storeDir string, root *GraphNode, nodes map[string]*GraphNode, concurrency int) error {
@@ -948,35 +1307,94 @@ func installParallel(ctx context.Context, projectDir, storeDir string, root *Gra
     return nil
 }
 
+// fetchToStore downloads, verifies and extracts n into its store path; git
+// and file: dependencies are packed from their checkout or directory instead.
+func fetchToStore(ctx context.Context, n *GraphNode, pkgStorePath string) error {
+    vStage("fetch", n.Name, n.Version)
+    logf("Downloading %s@%s\n", n.Name, n.Version)
+    if err := ensureDir(pkgStorePath); err != nil { return err }
+    if isGitSpec(n.Version) { return fetchGitToStore(ctx, n.Version, pkgStorePath) }
+    if isLocalSpec(n.Version) { return fetchLocalToStore(n.Version, pkgStorePath) }
+    tarPath := filepath.Join(pkgStorePath, "pkg.tgz")
+    if err := downloadToFileWithRetry(ctx, n.MD.Dist.Tarball, tarPath, 3); err != nil { return err }
+    if err := verifyIntegrityFile(tarPath, n.MD.Dist.Integrity, n.MD.Dist.Shasum); err != nil { return err }
+    vStage("extract", n.Name, n.Version)
+    return downloadAndExtractFromFile(tarPath, pkgStorePath)
+}
+
+// keyedMutex serializes work per key, e.g. a store path shared by several
+// peer instances of the same package.
+type keyedMutex struct {
+    mu sync.Mutex
+    m  map[string]*sync.Mutex
+}
+
+func (k *keyedMutex) lock(key string) func() {
+    k.mu.Lock()
+    if k.m == nil { k.m = make(map[string]*sync.Mutex) }
+    l, ok := k.m[key]
+    if !ok {
+        l = &sync.Mutex{}
+        k.m[key] = l
+    }
+    k.mu.Unlock()
+    l.Lock()
+    return l.Unlock
+}
+
+// ensureInStore makes sure n's store entry exists: the package is extracted
+// once under its plain version and cloned for each peer instance.
+func ensureInStore(ctx context.Context, n *GraphNode, storeDir string, locks *keyedMutex) error {
+    if n.linked() { return nil }
+    basePath := storePkgPath(storeDir, n.Name, n.Version)
+    defer locks.lock(basePath)()
+    if _, err := os.Stat(basePath); os.IsNotExist(err) {
+        if err := fetchToStore(ctx, n, basePath); err != nil {
+            // leave no half-extracted entry behind so a later install retries
+            _ = os.RemoveAll(basePath)
+            return err
+        }
+    }
+    instPath := storePkgPath(storeDir, n.Name, n.ref())
+    if instPath == basePath { return nil }
+    if _, err := os.Stat(instPath); os.IsNotExist(err) {
+        if err := cloneStoreEntry(basePath, instPath); err != nil {
+            _ = os.RemoveAll(instPath)
+            return err
+        }
+    }
+    return nil
+}
+
 // installGraph installs all nodes in parallel once, then links all roots into project and bins.
+// Optional nodes that fail are skipped with a warning and marked via Skipped;
+// nodes whose os/cpu/libc exclude the install target are not fetched at all.
 func installGraph(ctx context.Context, projectDir, storeDir string, roots []*GraphNode, nodes map[string]*GraphNode, concurrency int) error {
-    // Reuse installParallel workers but avoid duplicate fetch by marking existence
+    target := currentTarget()
+    for _, n := range nodes {
+        n.Unsupported = !target.supports(n.MD)
+        if !n.Unsupported { continue }
+        if n.Optional {
+            logf("Skipping %s@%s: unsupported platform %s\n", n.Name, n.Version, target)
+        } else {
+            fmt.Printf("Warning: skipping %s@%s: unsupported platform %s (os=%v cpu=%v libc=%v)\n", n.Name, n.Version, target, n.MD.OS, n.MD.CPU, n.MD.Libc)
+        }
+    }
     type task struct{ node *GraphNode }
     tasks := make(chan task)
     errCh := make(chan error, concurrency)
     done := make(chan struct{})
+    var locks keyedMutex
 
     for i := 0; i < concurrency; i++ {
         go func() {
             for t := range tasks {
                 n := t.node
-                pkgStorePath := storePkgPath(storeDir, n.Name, n.Version)
-                if _, err := os.Stat(pkgStorePath); os.IsNotExist(err) {
-                    vStage("fetch", n.Name, n.Version)
-                    logf("Downloading %s@%s\n", n.Name, n.Version)
-                    if err := ensureDir(pkgStorePath); err != nil { select { case errCh <- err: default: }; continue }
-                    tarPath := filepath.Join(pkgStorePath, "pkg.tgz")
-                    if err := downloadToFileWithRetry(ctx, n.MD.Dist.Tarball, tarPath, 3); err != nil { select { case errCh <- err: default: }; continue }
-                    if err := verifyIntegrityFile(tarPath, n.MD.Dist.Integrity, n.MD.Dist.Shasum); err != nil { select { case errCh <- err: default: }; continue }
-                    vStage("extract", n.Name, n.Version)
-                    if err := downloadAndExtractFromFile(tarPath, pkgStorePath); err != nil { select { case errCh <- err: default: }; continue }
-                }
-                storeNM := filepath.Join(pkgStorePath, "node_modules")
-                if err := ensureDir(storeNM); err != nil { select { case errCh <- err: default: }; continue }
-                vStage("link-deps", n.Name, n.Version)
-                for depName, depV := range n.Deps {
-                    depStore := storePkgPath(storeDir, depName, depV)
-                    if err := linkIntoNodeModules(storeNM, depName, depStore); err != nil { select { case errCh <- err: default: }; break }
+                if n.skip() { continue }
+                if err := ensureInStore(ctx, n, storeDir, &locks); err != nil {
+                    if !n.Optional { select { case errCh <- fmt.Errorf("%s@%s: %w", n.Name, n.Version, err): default: }; continue }
+                    n.Skipped = err.Error()
+                    fmt.Printf("Warning: skipping optional dependency %s@%s: %v\n", n.Name, n.Version, err)
                 }
             }
             done <- struct{}{}
@@ -992,14 +1410,34 @@ func installGraph(ctx context.Context, projectDir, storeDir string, roots []*Gra
         return err
     default:
     }
-    // link roots and bins
+    // link deps inside the store, leaving out skipped and filtered-out nodes
+    for _, n := range nodes {
+        if n.skip() || n.linked() { continue }
+        storeNM := filepath.Join(storePkgPath(storeDir, n.Name, n.ref()), "node_modules")
+        if err := ensureDir(storeNM); err != nil { return err }
+        vStage("link-deps", n.Name, n.Version)
+        for depName, depV := range n.Deps {
+            if d, ok := nodes[keyOf(depName, depV)]; !ok || d.skip() {
+                _ = os.RemoveAll(filepath.Join(storeNM, depName))
+                continue
+            }
+            if err := linkIntoNodeModules(storeNM, depName, storePkgPath(storeDir, depName, depV)); err != nil { return err }
+        }
+    }
+    return linkRoots(projectDir, storeDir, roots)
+}
+
+// linkRoots links roots and their bins into projectDir/node_modules.
+func linkRoots(projectDir, storeDir string, roots []*GraphNode) error {
     for _, r := range roots {
+        if r.skip() { continue }
         vStage("link-root", r.Name, r.Version)
-        if err := linkIntoNodeModules(filepath.Join(projectDir, "node_modules"), r.Name, storePkgPath(storeDir, r.Name, r.Version)); err != nil {
+        rootStore := storePkgPath(storeDir, r.Name, r.ref())
+        if err := linkIntoNodeModules(filepath.Join(projectDir, "node_modules"), r.Name, rootStore); err != nil {
             return err
         }
-        if pj, err := readPackageJSON(storePkgPath(storeDir, r.Name, r.Version)); err == nil {
-            _ = linkBins(projectDir, storePkgPath(storeDir, r.Name, r.Version), pj)
+        if pj, err := readPackageJSON(rootStore); err == nil {
+            _ = linkBins(projectDir, rootStore, pj)
         }
         vStage("done", r.Name, r.Version)
     }
@@ -1072,113 +1510,228 @@ func logf(format string, a ...interface{}) {
 // installCmd 명령어 정의
 var installCmd = &cobra.Command{
     Use:   "install [<package>[@version|@range] ...]",
-    Short: "Install one or more packages, or from lockfile",
+    Short: "Install one or more packages, or the project's package.json dependencies",
     Args:  cobra.ArbitraryArgs,
-    Run: func(cmd *cobra.Command, args []string) {
-        projectDir, _ := cmd.Flags().GetString("dir")
-        if projectDir == "" {
-            projectDir = "."
-        }
-        cfg, _ := loadConfig(projectDir)
-        // store dir
-        storeOverride, _ := cmd.Flags().GetString("store-dir")
-        if storeOverride == "" && cfg.StoreDir != "" {
-            storeOverride = cfg.StoreDir
-        }
-        if storeOverride != "" {
-            os.Setenv("WICK_STORE_DIR", storeOverride)
-        }
-        // registry flag overrides env
-        if r, _ := cmd.Flags().GetString("registry"); r != "" {
-            registryOverride = r
-        } else if cfg.Registry != "" {
-            registryOverride = cfg.Registry
-        }
-        frozen, _ := cmd.Flags().GetBool("frozen-lockfile")
-
-        ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-        defer cancel()
-        cache := make(map[string]*RootDoc)
-        var (
-            allNodes map[string]*GraphNode
-            roots []*GraphNode
-        )
-        if len(args) == 0 || frozen {
-            // Lockfile-driven install
-            var err error
-            allNodes, roots, err = nodesFromLockfile(ctx, projectDir, cache)
-            if err != nil {
-                fmt.Println("Error:", err)
-                os.Exit(1)
-            }
-            if len(args) > 0 {
-                // If frozen and explicit args present, ensure each root exists in lockfile
-                namesInLock := map[string]bool{}
-                for _, r := range roots { namesInLock[r.Name] = true }
-                for _, a := range args {
-                    name := a
-                    if at := strings.LastIndex(a, "@"); at > 0 { name = a[:at] }
-                    if !namesInLock[name] {
-                        fmt.Printf("Error: %s not present in lockfile (frozen)\n", name)
-                        os.Exit(1)
-                    }
+    Run:   runInstall,
+}
+
+// installSetup reads the project config and applies the store and registry
+// overrides shared by install-like commands.
+func installSetup(cmd *cobra.Command) (string, *Config) {
+    projectDir, _ := cmd.Flags().GetString("dir")
+    if projectDir == "" {
+        projectDir = "."
+    }
+    cfg, _ := loadConfig(projectDir)
+    // store dir
+    storeOverride, _ := cmd.Flags().GetString("store-dir")
+    if storeOverride == "" && cfg.StoreDir != "" {
+        storeOverride = cfg.StoreDir
+    }
+    if storeOverride != "" {
+        os.Setenv("WLIM_STORE_DIR", storeOverride)
+    }
+    // registry flag overrides env
+    if r, _ := cmd.Flags().GetString("registry"); r != "" {
+        registryOverride = r
+    } else if cfg.Registry != "" {
+        registryOverride = cfg.Registry
+    }
+    applyPlatformFlags(cmd)
+    autoInstallPeers, _ = cmd.Flags().GetBool("auto-install-peers")
+    autoInstallPeers = autoInstallPeers || cfg.AutoInstallPeers
+    ignoreScripts, _ = cmd.Flags().GetBool("ignore-scripts")
+    return projectDir, cfg
+}
+
+// runInstall installs args, or the project's package.json/lockfile roots when
+// no args are given. Commands calling it must register addInstallFlags.
+func runInstall(cmd *cobra.Command, args []string) {
+    projectDir, cfg := installSetup(cmd)
+    frozen, _ := cmd.Flags().GetBool("frozen-lockfile")
+    // --before re-resolves instead of installing what wlim.lock pins
+    before, err := applyCutoff(cmd, cfg)
+    if err == nil && before && frozen { err = fmt.Errorf("--before cannot be used with --frozen-lockfile") }
+    if err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+    }
+    filter, err := sectionFilterFromFlags(cmd)
+    if err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+    defer cancel()
+    cache := make(map[string]*RootDoc)
+    var (
+        allNodes map[string]*GraphNode
+        importers []*importer
+        overrides map[string]string
+    )
+    // The project's package.json (if any) lists the roots, along with those
+    // of its workspaces; args are installed next to them
+    hasManifest := false
+    if m, err := readProjectManifest(projectDir); err == nil {
+        hasManifest = true
+        importers, err = loadImporters(projectDir, cfg, m)
+        if err == nil { overrides, err = m.overrides() }
+        if err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
+        }
+    } else if !os.IsNotExist(err) {
+        fmt.Println("Error: package.json:", err)
+        os.Exit(1)
+    }
+    lf, lockErr := readLockfile(projectDir)
+    useLock := frozen || (len(args) == 0 && !hasManifest)
+    // changed overrides invalidate the lockfile like changed dependencies
+    lockCurrent := lockErr == nil && lockfileMatchesImporters(lf, importers) && maps.Equal(lf.Overrides, overrides)
+    if len(args) == 0 && hasManifest && lockCurrent && !before {
+        useLock = true
+    }
+    if useLock {
+        // Lockfile-driven install
+        if lockErr != nil {
+            fmt.Println("Error:", lockErr)
+            os.Exit(1)
+        }
+        if frozen && hasManifest && !lockCurrent {
+            fmt.Println("Error: wlim.lock is out of date with package.json (frozen)")
+            os.Exit(1)
+        }
+        nodes, roots, err := nodesFromLock(ctx, lf, cache)
+        if err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
+        }
+        allNodes, importers, overrides = nodes, lockImporters(lf, nodes, roots), lf.Overrides
+        if len(args) > 0 {
+            // If frozen and explicit args present, ensure each root exists in lockfile
+            namesInLock := map[string]bool{}
+            for _, r := range roots { namesInLock[r.Name] = true }
+            for _, a := range args {
+                name, _ := splitPackageArg(a)
+                if !namesInLock[name] {
+                    fmt.Printf("Error: %s not present in lockfile (frozen)\n", name)
+                    os.Exit(1)
                 }
             }
-        } else {
-            // Resolve graphs for each arg and merge the node set
-            allNodes = make(map[string]*GraphNode)
+        }
+    } else {
+        // Resolve graphs for each root of each importer and merge the node set
+        if len(importers) == 0 { importers = []*importer{{Dir: "."}} }
+        for _, arg := range args {
+            pkg, spec := splitPackageArg(arg)
+            dep := rootDep{Name: pkg, Spec: spec, Section: sectionProd, Dir: projectDir}
+            importers[0].Deps = replaceRootDep(importers[0].Deps, dep)
+        }
+        // versions from an outdated wlim.lock are kept where they still fit;
+        // args are resolved afresh
+        var pool versionPool
+        if lockErr == nil && !before {
+            pool = lockVersionPool(lf)
             for _, arg := range args {
-                pkg := arg
-                spec := "latest"
-                if at := strings.LastIndex(arg, "@"); at > 0 {
-                    pkg = arg[:at]
-                    spec = arg[at+1:]
-                    if spec == "" { spec = "latest" }
-                }
-                nodes, root, err := resolveGraph(ctx, pkg, spec, cache)
-                if err != nil {
-                    fmt.Println("Error:", err)
-                    os.Exit(1)
-                }
-                for k, n := range nodes { allNodes[k] = n }
-                roots = append(roots, root)
+                pkg, _ := splitPackageArg(arg)
+                delete(pool, pkg)
             }
         }
-        // Install in parallel and link each root
-        storeDir, err := defaultStoreDir()
+        res := newResolver(pool)
+        res.overrides, err = parseOverrides(overrides, projectDir)
+        if err == nil { allNodes, err = resolveImporters(ctx, importers, cache, res) }
         if err != nil {
             fmt.Println("Error:", err)
             os.Exit(1)
         }
-        conc, _ := cmd.Flags().GetInt("concurrency")
-        if !cmd.Flags().Changed("concurrency") && cfg.Concurrency > 0 {
-            conc = cfg.Concurrency
+    }
+    // Install in parallel and link each root
+    storeDir, err := defaultStoreDir()
+    if err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+    }
+    conc, _ := cmd.Flags().GetInt("concurrency")
+    if !cmd.Flags().Changed("concurrency") && cfg.Concurrency > 0 {
+        conc = cfg.Concurrency
+    }
+    if conc <= 0 { conc = runtime.NumCPU() }
+    // visual flags
+    if f, _ := cmd.Flags().GetString("log-format"); f != "" { logFormat = f }
+    logNoColor, _ = cmd.Flags().GetBool("no-color")
+    showProgress, _ = cmd.Flags().GetBool("progress")
+    // --filter/-r link only some workspaces; the lockfile keeps all of them
+    selected, err := selectImporters(projectDir, importers)
+    if err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+    }
+    // Only the packages this install links need a supported engine
+    nodeVersion, strict := engineTarget(cmd, cfg)
+    if err := checkEngines(selected, importerNodes(selected, allNodes, filter), nodeVersion, strict); err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+    }
+    // Scripts are not bound by the resolve/fetch timeout; workspaces run
+    // theirs before the root project
+    var scriptDirs []string
+    if !ignoreScripts && len(args) == 0 && hasManifest {
+        for _, imp := range selected {
+            if imp.Dir != "." { scriptDirs = append(scriptDirs, filepath.Join(projectDir, filepath.FromSlash(imp.Dir))) }
         }
-        if conc <= 0 { conc = runtime.NumCPU() }
-        // visual flags
-        if f, _ := cmd.Flags().GetString("log-format"); f != "" { logFormat = f }
-        logNoColor, _ = cmd.Flags().GetBool("no-color")
-        showProgress, _ = cmd.Flags().GetBool("progress")
-        if err := installGraph(ctx, projectDir, storeDir, roots, allNodes, conc); err != nil {
+        if len(selected) > 0 && selected[0].Dir == "." { scriptDirs = append(scriptDirs, projectDir) }
+    }
+    for _, dir := range scriptDirs {
+        if err := runProjectLifecycle(context.Background(), dir, []string{"preinstall"}); err != nil {
             fmt.Println("Error:", err)
             os.Exit(1)
         }
-        // Write lockfile
-        if err := writeLockfile(projectDir, roots, allNodes); err != nil {
-            fmt.Println("Warning: failed to write lockfile:", err)
+    }
+    // Only the selected sections are installed; the lockfile keeps everything
+    installNodes, err := installImporters(ctx, projectDir, storeDir, selected, allNodes, filter, conc)
+    if err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+    }
+    if !ignoreScripts {
+        if err := buildDependencies(context.Background(), projectDir, storeDir, installNodes, cfg.OnlyBuiltDependencies); err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
+        }
+    }
+    // Write lockfile
+    if err := writeImportersLockfile(projectDir, importers, allNodes, overrides); err != nil {
+        fmt.Println("Warning: failed to write lockfile:", err)
+    }
+    for _, dir := range scriptDirs {
+        if err := runProjectLifecycle(context.Background(), dir, projectInstallEvents); err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
         }
-        fmt.Println("Done.")
-    },
+    }
+    fmt.Println("Done.")
+}
+
+// addInstallFlags registers the flags runInstall reads.
+func addInstallFlags(cmd *cobra.Command) {
+    cmd.Flags().String("dir", ".", "Project directory where node_modules resides")
+    cmd.Flags().String("store-dir", "", "Override content-addressable store directory (defaults to ~/.wlim/store/v3 or WLIM_STORE_DIR)")
+    cmd.Flags().Int("concurrency", runtime.NumCPU(), "Parallel downloads/extract workers")
+    cmd.Flags().String("registry", "", "Override npm registry base URL (takes precedence over WLIM_REGISTRY)")
+    cmd.Flags().Bool("frozen-lockfile", false, "Use existing wlim.lock exclusively and fail on mismatches")
+    cmd.Flags().String("log-format", "fancy", "Log format: fancy|plain")
+    cmd.Flags().Bool("no-color", false, "Disable ANSI colors in logs")
+    cmd.Flags().Bool("progress", true, "Show progress bar")
+    cmd.Flags().Bool("auto-install-peers", false, "Install missing peer dependencies automatically")
+    cmd.Flags().Bool("ignore-scripts", false, "Do not run lifecycle scripts of dependencies or the project")
+    addCutoffFlag(cmd)
+    addEngineFlags(cmd)
+    addSectionFlags(cmd)
+    addPlatformFlags(cmd)
 }
 
 func init() {
-    installCmd.Flags().String("dir", ".", "Project directory where node_modules resides")
-    installCmd.Flags().String("store-dir", "", "Override content-addressable store directory (defaults to ~/.wlim/store/v3 or WLIM_STORE_DIR)")
-    installCmd.Flags().Int("concurrency", runtime.NumCPU(), "Parallel downloads/extract workers")
-    installCmd.Flags().String("registry", "", "Override npm registry base URL (takes precedence over WLIM_REGISTRY)")
-    installCmd.Flags().Bool("frozen-lockfile", false, "Use existing wlim.lock exclusively and fail on mismatches")
-    installCmd.Flags().String("log-format", "fancy", "Log format: fancy|plain")
-    installCmd.Flags().Bool("no-color", false, "Disable ANSI colors in logs")
-    installCmd.Flags().Bool("progress", true, "Show progress bar")
+    addInstallFlags(installCmd)
     rootCmd.AddCommand(installCmd)
 }
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+  "archive/tar"
+  "bytes"
+  "compress/gzip"
+  "context"
+  "crypto/sha512"
+  "encoding/base64"
+  "encoding/json"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/spf13/pflag"
+)
+
+// testPkg describes one version served by newTestRegistry.
+type testPkg struct {
+  Name    string
+  Version string
+  Deps    map[string]string
+  Extra   map[string]any    // additional package.json / packument fields
+  Files   map[string]string // extra tarball files besides package.json
+  Broken  bool              // tarball download returns 404
+  Tags    []string          // dist-tags besides latest pointing at this version
+  Time    string            // publish time in the packument's time map
+}
+
+func buildTarball(t *testing.T, manifest map[string]any, files map[string]string) []byte {
+  t.Helper()
+  var buf bytes.Buffer
+  gz := gzip.NewWriter(&buf)
+  tw := tar.NewWriter(gz)
+  pj, _ := json.MarshalIndent(manifest, "", "  ")
+  all := map[string]string{"package.json": string(pj)}
+  for k, v := range files { all[k] = v }
+  for name, body := range all {
+    mode := int64(0o644)
+    if strings.HasPrefix(name, "bin/") { mode = 0o755 }
+    if err := tw.WriteHeader(&tar.Header{Name: "package/" + name, Mode: mode, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil { t.Fatal(err) }
+    if _, err := tw.Write([]byte(body)); err != nil { t.Fatal(err) }
+  }
+  tw.Close()
+  gz.Close()
+  return buf.Bytes()
+}
+
+// newTestRegistry serves packuments and tarballs for pkgs and points wlim at
+// it, with the cache and store isolated in temp dirs. The last version listed
+// for a name becomes its latest dist-tag.
+func newTestRegistry(t *testing.T, pkgs ...testPkg) *httptest.Server {
+  t.Helper()
+  docs := map[string]map[string]any{}
+  tarballs := map[string][]byte{}
+  mux := http.NewServeMux()
+  srv := httptest.NewServer(mux)
+  t.Cleanup(srv.Close)
+  for _, p := range pkgs {
+    manifest := map[string]any{"name": p.Name, "version": p.Version}
+    if p.Deps != nil { manifest["dependencies"] = p.Deps }
+    for k, v := range p.Extra { manifest[k] = v }
+    tgz := buildTarball(t, manifest, p.Files)
+    tarPath := "/-/" + strings.ReplaceAll(p.Name, "/", "-") + "-" + p.Version + ".tgz"
+    if !p.Broken { tarballs[tarPath] = tgz }
+    sum := sha512.Sum512(tgz)
+    ver := map[string]any{}
+    for k, v := range manifest { ver[k] = v }
+    ver["dist"] = map[string]any{"tarball": srv.URL + tarPath, "integrity": "sha512-" + base64.StdEncoding.EncodeToString(sum[:])}
+    doc, ok := docs[p.Name]
+    if !ok {
+      doc = map[string]any{"name": p.Name, "dist-tags": map[string]string{}, "versions": map[string]any{}, "time": map[string]string{}}
+      docs[p.Name] = doc
+    }
+    doc["versions"].(map[string]any)[p.Version] = ver
+    doc["dist-tags"].(map[string]string)["latest"] = p.Version
+    for _, tag := range p.Tags { doc["dist-tags"].(map[string]string)[tag] = p.Version }
+    if p.Time != "" { doc["time"].(map[string]string)[p.Version] = p.Time }
+  }
+  mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+    if tgz, ok := tarballs[r.URL.Path]; ok { w.Write(tgz); return }
+    doc, ok := docs[strings.TrimPrefix(r.URL.Path, "/")]
+    if !ok { http.NotFound(w, r); return }
+    json.NewEncoder(w).Encode(doc)
+  })
+  old := registryOverride
+  registryOverride = srv.URL
+  t.Cleanup(func() { registryOverride = old })
+  t.Setenv("WLIM_CACHE_DIR", t.TempDir())
+  t.Setenv("WLIM_STORE_DIR", t.TempDir())
+  return srv
+}
+
+func TestResolveRootsFromManifest(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"c": "^1.0.0"}},
+    testPkg{Name: "b", Version: "2.0.0"},
+    testPkg{Name: "c", Version: "1.1.0"},
+  )
+  proj := t.TempDir()
+  pj := `{"name":"app","dependencies":{"a":"^1.0.0"},"devDependencies":{"b":"^2.0.0"}}`
+  if err := os.WriteFile(filepath.Join(proj, "package.json"), []byte(pj), 0o644); err != nil { t.Fatal(err) }
+  m, err := readProjectManifest(proj)
+  if err != nil { t.Fatalf("manifest: %v", err) }
+  deps := m.rootDeps()
+
+  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+  defer cancel()
+  nodes, roots, rootSpecs, err := resolveRoots(ctx, deps, make(map[string]*RootDoc), nil)
+  if err != nil { t.Fatalf("resolveRoots: %v", err) }
+  if len(roots) != 2 || len(nodes) != 3 { t.Fatalf("unexpected graph: roots=%d nodes=%d", len(roots), len(nodes)) }
+  if rootSpecs["b"].Section != sectionDev || rootSpecs["a"].Spec != "^1.0.0" { t.Fatalf("unexpected root specs: %+v", rootSpecs) }
+  if err := writeLockfile(proj, roots, nodes, rootSpecs); err != nil { t.Fatalf("write: %v", err) }
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatalf("read: %v", err) }
+  if !lockfileMatchesManifest(lf, deps) { t.Fatalf("lockfile should match manifest: %+v", lf.RootSpecs) }
+
+  // a changed spec invalidates the lockfile
+  m.Dependencies["a"] = "^1.1.0"
+  if lockfileMatchesManifest(lf, m.rootDeps()) { t.Fatalf("lockfile should be stale") }
+}
+
+// runCLI executes wlim with args and resets the flags it touched afterwards,
+// since cobra commands are package globals shared by all tests.
+func runCLI(t *testing.T, args ...string) {
+  t.Helper()
+  rootCmd.SetArgs(args)
+  err := rootCmd.Execute()
+  resetFlag := func(f *pflag.Flag) {
+    if !f.Changed { return }
+    if sv, ok := f.Value.(pflag.SliceValue); ok {
+      _ = sv.Replace(nil)
+    } else {
+      _ = f.Value.Set(f.DefValue)
+    }
+    f.Changed = false
+  }
+  var reset func(c *cobra.Command)
+  reset = func(c *cobra.Command) {
+    c.Flags().VisitAll(resetFlag)
+    c.PersistentFlags().VisitAll(resetFlag)
+    for _, sub := range c.Commands() { reset(sub) }
+  }
+  reset(rootCmd)
+  if err != nil { t.Fatalf("wlim %v: %v", args, err) }
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+  t.Helper()
+  old := os.Stdout
+  r, w, err := os.Pipe()
+  if err != nil { t.Fatal(err) }
+  out := make(chan string)
+  go func() { b, _ := io.ReadAll(r); out <- string(b) }()
+  os.Stdout = w
+  defer func() { os.Stdout = old }()
+  fn()
+  w.Close()
+  return <-out
+}
+
+func TestInstallProdSkipsDevDependencies(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"c": "1.0.0"}},
+    testPkg{Name: "b", Version: "1.0.0", Deps: map[string]string{"d": "1.0.0"}},
+    testPkg{Name: "c", Version: "1.0.0"},
+    testPkg{Name: "d", Version: "1.0.0"},
+  )
+  proj := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(`{"dependencies":{"a":"1.0.0"},"devDependencies":{"b":"1.0.0"}}`), 0o644)
+
+  runCLI(t, "install", "--dir", proj)
+  if _, err := os.Lstat(filepath.Join(proj, "node_modules", "b")); err != nil { t.Fatalf("b should be linked by a full install: %v", err) }
+
+  runCLI(t, "install", "--prod", "--dir", proj)
+  if _, err := os.Lstat(filepath.Join(proj, "node_modules", "a")); err != nil { t.Fatalf("a not linked: %v", err) }
+  if _, err := os.Lstat(filepath.Join(proj, "node_modules", "b")); !os.IsNotExist(err) { t.Fatalf("b should be unlinked with --prod") }
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatalf("lockfile: %v", err) }
+  if _, ok := lf.Packages["d@1.0.0"]; !ok { t.Fatalf("lockfile should keep dev graph: %+v", lf.Packages) }
+}
+
+func TestInstallArgsKeepManifestRoots(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0", Time: "2025-06-01T00:00:00Z"},
+    testPkg{Name: "a", Version: "1.1.0", Time: "2026-06-01T00:00:00Z"},
+    testPkg{Name: "b", Version: "1.0.0"},
+  )
+  t.Cleanup(func() { releaseCutoff = time.Time{} })
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"a":"^1.0.0"}}`)
+  runCLI(t, "install", "--dir", proj, "--before", "2026-01-01")
+  lockHas(t, proj, "a@1.0.0")
+
+  runCLI(t, "install", "--dir", proj, "b")
+  lockHas(t, proj, "a@1.0.0", "b@1.0.0")
+  runCLI(t, "install", "--dir", proj)
+  if lf := lockHas(t, proj, "a@1.0.0"); len(lf.Packages) != 1 { t.Fatalf("a should stay pinned alone: %+v", lf.Packages) }
+}
+
+func TestInstallStoreDirFlag(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t, testPkg{Name: "a", Version: "1.0.0"})
+  proj, store := t.TempDir(), t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"a":"1.0.0"}}`)
+
+  runCLI(t, "install", "--dir", proj, "--store-dir", store)
+  got, err := os.Readlink(filepath.Join(proj, "node_modules", "a"))
+  if err != nil { t.Fatalf("a not linked: %v", err) }
+  if want := storePkgPath(store, "a", "1.0.0"); got != want { t.Fatalf("a -> %s, want %s", got, want) }
+}
+
+func TestInstallSkipsFailingOptionalDependency(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0", Extra: map[string]any{"optionalDependencies": map[string]string{"native": "^1.0.0", "missing": "^1.0.0"}}},
+    testPkg{Name: "native", Version: "1.0.0", Broken: true},
+  )
+  proj := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(`{"dependencies":{"a":"1.0.0"}}`), 0o644)
+
+  runCLI(t, "install", "--dir", proj)
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatalf("lockfile: %v", err) }
+  lp := lf.Packages["native@1.0.0"]
+  if !lp.Optional || lp.Skipped == "" { t.Fatalf("native should be recorded as a skipped optional package: %+v", lp) }
+  if lf.Packages["a@1.0.0"].Optional { t.Fatalf("a is required") }
+  store, _ := defaultStoreDir()
+  if _, err := os.Lstat(filepath.Join(store, "a", "1.0.0", "node_modules", "native")); !os.IsNotExist(err) {
+    t.Fatalf("skipped optional dependency should not be linked")
+  }
+  if _, err := os.Stat(filepath.Join(store, "native", "1.0.0")); !os.IsNotExist(err) {
+    t.Fatalf("failed fetch should not leave a store entry")
+  }
+}
@@ -19,7 +19,7 @@ func TestInstallEscapeStringRegexp(t *testing.T) {
   defer cancel()
   cache := make(map[string]*RootDoc)
   pkg := "escape-string-regexp"
-  nodes, root, err := resolveGraph(ctx, pkg, "latest", cache)
+  nodes, root, err := resolveGraph(ctx, pkg, "latest", cache, nil)
   if err != nil { t.Fatalf("resolveGraph: %v", err) }
   storeDir, err := defaultStoreDir()
   if err != nil { t.Fatalf("store: %v", err) }
@@ -44,7 +44,7 @@ func TestInstallTwoPackagesIntegration(t *testing.T) {
   allNodes := map[string]*GraphNode{}
   var roots []*GraphNode
   for _, p := range pkgs {
-    nodes, root, err := resolveGraph(ctx, p, "latest", cache)
+    nodes, root, err := resolveGraph(ctx, p, "latest", cache, nil)
     if err != nil { t.Fatalf("resolveGraph(%s): %v", p, err) }
     for k, n := range nodes { allNodes[k] = n }
     roots = append(roots, root)
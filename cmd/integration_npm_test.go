@@ -30,7 +30,7 @@ func TestNPMIntegration(t *testing.T) {
 
   // Pick a very small, dependency-free package
   const pkg = "escape-string-regexp" // commonly dependency-free and lightweight
-  nodes, root, err := resolveGraph(ctx, pkg, "latest", cache)
+  nodes, root, err := resolveGraph(ctx, pkg, "latest", cache, nil)
   if err != nil { t.Fatalf("resolveGraph: %v", err) }
 
   storeDir, err := defaultStoreDir()
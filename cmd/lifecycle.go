@@ -0,0 +1,207 @@
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "regexp"
+  "runtime"
+  "sort"
+  "strings"
+)
+
+// builtMarker is written into a store instance once its install scripts ran,
+// so other projects sharing the store do not build it again.
+const builtMarker = ".wlim-built"
+
+// ignoreScripts is set by --ignore-scripts; it also skips the prepare
+// scripts of git dependencies.
+var ignoreScripts bool
+
+// dependencyScriptEvents run for dependencies, in this order, after linking.
+var dependencyScriptEvents = []string{"preinstall", "install", "postinstall"}
+
+// hasInstallScripts returns the install-time scripts of a package. Like npm,
+// a binding.gyp without preinstall/install scripts implies `node-gyp rebuild`.
+func hasInstallScripts(pkgDir string, pj *pkgJSON) map[string]string {
+  out := map[string]string{}
+  for _, ev := range dependencyScriptEvents {
+    if s := pj.Scripts[ev]; s != "" { out[ev] = s }
+  }
+  if out["install"] == "" && out["preinstall"] == "" {
+    if _, err := os.Stat(filepath.Join(pkgDir, "binding.gyp")); err == nil { out["install"] = "node-gyp rebuild" }
+  }
+  return out
+}
+
+// allowedToBuild reports whether name is in the onlyBuiltDependencies allowlist.
+func allowedToBuild(name string, allow []string) bool {
+  for _, a := range allow {
+    if a == name { return true }
+  }
+  return false
+}
+
+// topoOrder returns nodes with dependencies before their dependents; cycles
+// are broken arbitrarily but deterministically.
+func topoOrder(nodes map[string]*GraphNode) []*GraphNode {
+  keys := make([]string, 0, len(nodes))
+  for k := range nodes { keys = append(keys, k) }
+  sort.Strings(keys)
+  seen := make(map[string]bool, len(nodes))
+  var order []*GraphNode
+  var visit func(k string)
+  visit = func(k string) {
+    if seen[k] { return }
+    seen[k] = true
+    n := nodes[k]
+    deps := make([]string, 0, len(n.Deps))
+    for depName, depRef := range n.Deps { deps = append(deps, keyOf(depName, depRef)) }
+    sort.Strings(deps)
+    for _, d := range deps {
+      if _, ok := nodes[d]; ok { visit(d) }
+    }
+    order = append(order, n)
+  }
+  for _, k := range keys { visit(k) }
+  return order
+}
+
+// buildDependencies runs preinstall/install/postinstall of installed packages
+// in dependency order, preparing git dependencies first. Only packages listed
+// in allow are built; the others are reported so the user can opt in.
+func buildDependencies(ctx context.Context, projectDir, storeDir string, nodes map[string]*GraphNode, allow []string) error {
+  var ignored []string
+  for _, n := range topoOrder(nodes) {
+    if n.skip() || n.linked() { continue }
+    pkgDir := storePkgPath(storeDir, n.Name, n.ref())
+    if _, err := os.Stat(filepath.Join(pkgDir, builtMarker)); err == nil { continue }
+    pj, err := readPackageJSON(pkgDir)
+    if err != nil { continue }
+    scripts := hasInstallScripts(pkgDir, pj)
+    // git dependencies are stored unprepared
+    prepare := isGitSpec(n.Version) && pj.Scripts["prepare"] != ""
+    if len(scripts) == 0 && !prepare { continue }
+    if !allowedToBuild(n.pkgName(), allow) {
+      ignored = append(ignored, n.pkgName())
+      continue
+    }
+    if prepare { err = prepareGitPackage(ctx, n.Version, pkgDir) }
+    if err == nil { err = buildPackage(ctx, projectDir, storeDir, n, pkgDir, pj, scripts) }
+    if err != nil {
+      if !n.Optional { return fmt.Errorf("%s@%s: %w", n.Name, n.Version, err) }
+      fmt.Printf("Warning: build of optional dependency %s@%s failed: %v\n", n.Name, n.Version, err)
+      continue
+    }
+    _ = os.WriteFile(filepath.Join(pkgDir, builtMarker), nil, 0o644)
+  }
+  if len(ignored) > 0 {
+    sort.Strings(ignored)
+    fmt.Printf("Ignored build scripts of: %s\nAdd them to onlyBuiltDependencies in wlim.json to run them.\n", strings.Join(uniqueStrings(ignored), ", "))
+  }
+  return nil
+}
+
+func buildPackage(ctx context.Context, projectDir, storeDir string, n *GraphNode, pkgDir string, pj *pkgJSON, scripts map[string]string) error {
+  // bins of the package's own dependencies, as its scripts expect
+  for depName, depRef := range n.Deps {
+    depDir := storePkgPath(storeDir, depName, depRef)
+    if dpj, err := readPackageJSON(depDir); err == nil { _ = linkBins(pkgDir, depDir, dpj) }
+  }
+  pathDirs := []string{filepath.Join(pkgDir, "node_modules", ".bin")}
+  if abs, err := filepath.Abs(filepath.Join(projectDir, "node_modules", ".bin")); err == nil { pathDirs = append(pathDirs, abs) }
+  for _, ev := range dependencyScriptEvents {
+    script := scripts[ev]
+    if script == "" { continue }
+    fmt.Printf("Running %s of %s@%s\n", ev, n.Name, n.Version)
+    if err := runScript(ctx, pkgDir, pj, ev, script, pathDirs, nil, stdIO()); err != nil { return fmt.Errorf("%s script: %w", ev, err) }
+  }
+  return nil
+}
+
+// scriptIO are the standard streams of a script; recursive runs replace them
+// with prefixed writers.
+type scriptIO struct {
+  Stdin          io.Reader
+  Stdout, Stderr io.Writer
+}
+
+func stdIO() scriptIO { return scriptIO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr} }
+
+// runScript runs one package.json script through the shell in dir, with npm's
+// lifecycle environment and pathDirs prepended to PATH. Extra args are
+// appended to the script as npm does.
+func runScript(ctx context.Context, dir string, pj *pkgJSON, event, script string, pathDirs, args []string, sio scriptIO) error {
+  for _, a := range args { script += " " + shellQuote(a) }
+  var c *exec.Cmd
+  if runtime.GOOS == "windows" {
+    c = exec.CommandContext(ctx, "cmd", "/d", "/s", "/c", script)
+  } else {
+    c = exec.CommandContext(ctx, "sh", "-c", script)
+  }
+  c.Dir = dir
+  c.Env = scriptEnv(dir, pj, event, script, pathDirs)
+  c.Stdin, c.Stdout, c.Stderr = sio.Stdin, sio.Stdout, sio.Stderr
+  return c.Run()
+}
+
+func shellQuote(s string) string {
+  if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`&|;<>()*?[]#~!{}") { return s }
+  if runtime.GOOS == "windows" { return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"` }
+  return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var envKeySanitizer = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// scriptEnv builds the environment of a lifecycle script: the current
+// environment, npm_lifecycle_*, npm_package_* flattened from package.json
+// and PATH with pathDirs first.
+func scriptEnv(dir string, pj *pkgJSON, event, script string, pathDirs []string) []string {
+  env := make([]string, 0, len(os.Environ())+16)
+  pathKey := "PATH"
+  for _, kv := range os.Environ() {
+    k, _, _ := strings.Cut(kv, "=")
+    if strings.EqualFold(k, "PATH") {
+      pathKey = k
+      continue
+    }
+    if strings.HasPrefix(k, "npm_package_") || strings.HasPrefix(k, "npm_lifecycle_") { continue }
+    env = append(env, kv)
+  }
+  path := strings.Join(pathDirs, string(os.PathListSeparator))
+  if cur := os.Getenv("PATH"); cur != "" { path += string(os.PathListSeparator) + cur }
+  env = append(env, pathKey+"="+path)
+  if event != "" { env = append(env, "npm_lifecycle_event="+event, "npm_lifecycle_script="+script) }
+  if abs, err := filepath.Abs(filepath.Join(dir, "package.json")); err == nil { env = append(env, "npm_package_json="+abs) }
+  var flatten func(prefix string, v any)
+  flatten = func(prefix string, v any) {
+    switch t := v.(type) {
+    case map[string]any:
+      for k, vv := range t { flatten(prefix+"_"+envKeySanitizer.ReplaceAllString(k, "_"), vv) }
+    case []any:
+      for i, vv := range t { flatten(fmt.Sprintf("%s_%d", prefix, i), vv) }
+    case string:
+      env = append(env, prefix+"="+t)
+    case bool, float64:
+      env = append(env, fmt.Sprintf("%s=%v", prefix, t))
+    }
+  }
+  if pj != nil {
+    for k, v := range pj.Raw {
+      if k == "readme" { continue }
+      flatten("npm_package_"+envKeySanitizer.ReplaceAllString(k, "_"), v)
+    }
+  }
+  return env
+}
+
+func uniqueStrings(in []string) []string {
+  var out []string
+  for i, s := range in {
+    if i == 0 || s != in[i-1] { out = append(out, s) }
+  }
+  return out
+}
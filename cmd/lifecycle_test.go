@@ -0,0 +1,37 @@
+package cmd
+
+import (
+  "os"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+)
+
+func TestInstallRunsAllowedScriptsInDependencyOrder(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("scripts use sh") }
+  script := map[string]any{"scripts": map[string]string{"postinstall": `echo "$npm_lifecycle_event $npm_package_name $npm_package_version" >> "$WLIM_TEST_LOG"`}}
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"b": "1.0.0", "c": "1.0.0"}, Extra: script},
+    testPkg{Name: "b", Version: "1.0.0", Extra: script},
+    testPkg{Name: "c", Version: "1.0.0", Extra: script},
+  )
+  log := filepath.Join(t.TempDir(), "log")
+  t.Setenv("WLIM_TEST_LOG", log)
+  proj := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(`{"dependencies":{"a":"1.0.0"}}`), 0o644)
+  _ = os.WriteFile(filepath.Join(proj, "wlim.json"), []byte(`{"onlyBuiltDependencies":["a","b"]}`), 0o644)
+
+  runCLI(t, "install", "--ignore-scripts", "--dir", proj)
+  if _, err := os.Stat(log); !os.IsNotExist(err) { t.Fatalf("--ignore-scripts should not run anything") }
+
+  runCLI(t, "install", "--dir", proj)
+  b, err := os.ReadFile(log)
+  if err != nil { t.Fatalf("scripts did not run: %v", err) }
+  want := "postinstall b 1.0.0\npostinstall a 1.0.0\n"
+  if string(b) != want { t.Fatalf("unexpected script log:\n%s\nwant:\n%s", b, want) }
+
+  // built packages are marked in the store and not rebuilt
+  runCLI(t, "install", "--dir", proj)
+  if b2, _ := os.ReadFile(log); strings.Count(string(b2), "\n") != 2 { t.Fatalf("scripts ran again:\n%s", b2) }
+}
@@ -5,6 +5,7 @@ import (
   "fmt"
   "os"
   "sort"
+  "strings"
   "github.com/spf13/cobra"
 )
 
@@ -14,6 +15,10 @@ var listCmd = &cobra.Command{
   Run: func(cmd *cobra.Command, args []string) {
     projectDir, _ := cmd.Flags().GetString("dir")
     if projectDir == "" { projectDir = "." }
+    if dep, _ := cmd.Flags().GetBool("deprecated"); dep {
+      if err := printDeprecated(projectDir); err != nil { fmt.Println("Error:", err) }
+      return
+    }
     roots, pkgs, err := listLockfile(projectDir)
     if err != nil { fmt.Println("Error:", err); return }
     format, _ := cmd.Flags().GetString("format")
@@ -42,6 +47,7 @@ func init() {
   listCmd.Flags().String("dir", ".", "Project directory where node_modules resides")
   listCmd.Flags().Bool("json", false, "Output lockfile contents as JSON")
   listCmd.Flags().String("format", "table", "Output format: table|json|yaml")
+  listCmd.Flags().Bool("deprecated", false, "List deprecated packages in the lockfile and what depends on them")
   rootCmd.AddCommand(listCmd)
 }
 
@@ -87,3 +93,21 @@ func printLockfileYAML(roots []string, pkgs map[string]LockPackage) error {
   }
   return nil
 }
+
+// printDeprecated lists the deprecated packages of the lockfile with their
+// messages and the chain that pulls each of them in.
+func printDeprecated(projectDir string) error {
+  lf, err := readLockfile(projectDir)
+  if err != nil { return err }
+  deps := lockDeprecations(lf)
+  if len(deps) == 0 {
+    fmt.Println("No deprecated packages.")
+    return nil
+  }
+  fmt.Println("Deprecated:")
+  for _, d := range deps {
+    fmt.Printf("  %s: %s\n", d.key, d.message)
+    if len(d.path) > 0 { fmt.Printf("    %s\n", strings.Join(d.path, " > ")) }
+  }
+  return nil
+}
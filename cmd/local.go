@@ -0,0 +1,244 @@
+package cmd
+
+import (
+  "archive/tar"
+  "compress/gzip"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strings"
+)
+
+// Local dependencies: file:<path> to a package directory or tarball, copied
+// into the store under <name>/file-<hash> of its content, and link:<path>,
+// symlinked as is with no store entry. Node versions carry the absolute path
+// (plus #<hash> for file:); wlim.lock records paths relative to the project,
+// see localizeLockfile.
+const (
+  filePrefix = "file:"
+  linkPrefix = "link:"
+)
+
+func isLocalSpec(spec string) bool {
+  return strings.HasPrefix(spec, filePrefix) || strings.HasPrefix(spec, linkPrefix)
+}
+
+// splitLocal splits a local spec or node version into its prefix, path and
+// content hash.
+func splitLocal(spec string) (prefix, path, hash string) {
+  for _, p := range []string{filePrefix, linkPrefix} {
+    if rest, ok := strings.CutPrefix(spec, p); ok {
+      path, hash, _ = strings.Cut(rest, "#")
+      return p, path, hash
+    }
+  }
+  return "", "", ""
+}
+
+// absLocalSpec makes the path of a local spec absolute, relative to base
+// (the working directory when base is empty). Other specs are returned as is.
+func absLocalSpec(spec, base string) string {
+  prefix, p, _ := splitLocal(spec)
+  if prefix == "" { return spec }
+  p = filepath.FromSlash(p)
+  if !filepath.IsAbs(p) { p = filepath.Join(base, p) }
+  if abs, err := filepath.Abs(p); err == nil { p = abs }
+  return prefix + p
+}
+
+// localDir is the directory relative local specs of a node's own
+// dependencies resolve against: the package directory, or the directory of
+// a local tarball. It is empty for other nodes.
+func localDir(version string) string {
+  prefix, p, _ := splitLocal(version)
+  if prefix == "" { return "" }
+  if fi, err := os.Stat(p); err == nil && !fi.IsDir() { return filepath.Dir(p) }
+  return p
+}
+
+// linked reports whether n is a link: dependency, symlinked to its
+// directory instead of installed from the store.
+func (n *GraphNode) linked() bool { return strings.HasPrefix(n.Version, linkPrefix) }
+
+// resolveLocal reads the package a local spec with an absolute path points
+// at and returns its node version and metadata. The content hash of a file:
+// package is computed afresh each time.
+func resolveLocal(spec string) (string, *PackageMetadata, error) {
+  prefix, p, _ := splitLocal(spec)
+  if !filepath.IsAbs(p) { return "", nil, fmt.Errorf("%s: path is not absolute", spec) }
+  fi, err := os.Stat(p)
+  if err != nil { return "", nil, err }
+  if prefix == linkPrefix {
+    // a linked directory is used as is; its package.json only names it
+    md := &PackageMetadata{}
+    if b, err := os.ReadFile(filepath.Join(p, "package.json")); err == nil {
+      var full PackageMetadata
+      if err := json.Unmarshal(b, &full); err != nil { return "", nil, fmt.Errorf("%s: package.json: %w", p, err) }
+      md.Name, md.Version = full.Name, full.Version
+    }
+    return linkPrefix + p, md, nil
+  }
+  var md *PackageMetadata
+  var hash string
+  if fi.IsDir() {
+    b, err := os.ReadFile(filepath.Join(p, "package.json"))
+    if err != nil { return "", nil, err }
+    md = &PackageMetadata{}
+    if err := json.Unmarshal(b, md); err != nil { return "", nil, fmt.Errorf("%s: package.json: %w", p, err) }
+    hash, err = hashDir(p)
+    if err != nil { return "", nil, err }
+  } else {
+    md, err = tarballMetadata(p)
+    if err != nil { return "", nil, err }
+    hash, err = hashFile(p)
+    if err != nil { return "", nil, err }
+  }
+  return filePrefix + p + "#" + hash, md, nil
+}
+
+// tarballMetadata reads package/package.json from a package tarball.
+func tarballMetadata(tarGzPath string) (*PackageMetadata, error) {
+  f, err := os.Open(tarGzPath)
+  if err != nil { return nil, err }
+  defer f.Close()
+  gz, err := gzip.NewReader(f)
+  if err != nil { return nil, fmt.Errorf("%s: gzip: %w", tarGzPath, err) }
+  defer gz.Close()
+  tr := tar.NewReader(gz)
+  for {
+    hdr, err := tr.Next()
+    if err == io.EOF { return nil, fmt.Errorf("%s: no package.json", tarGzPath) }
+    if err != nil { return nil, err }
+    // like downloadAndExtractFromFile, the top-level folder is dropped
+    if parts := strings.SplitN(hdr.Name, "/", 2); len(parts) != 2 || parts[1] != "package.json" { continue }
+    var md PackageMetadata
+    if err := json.NewDecoder(tr).Decode(&md); err != nil { return nil, fmt.Errorf("%s: package.json: %w", tarGzPath, err) }
+    return &md, nil
+  }
+}
+
+func hashFile(path string) (string, error) {
+  f, err := os.Open(path)
+  if err != nil { return "", err }
+  defer f.Close()
+  h := sha256.New()
+  if _, err := io.Copy(h, f); err != nil { return "", err }
+  return hex.EncodeToString(h.Sum(nil)[:20]), nil
+}
+
+// hashDir hashes the paths, modes and contents of the files in dir, leaving
+// out .git and node_modules.
+func hashDir(dir string) (string, error) {
+  var files []string
+  err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+    if err != nil { return err }
+    if info.Name() == ".git" || info.Name() == "node_modules" {
+      if info.IsDir() { return filepath.SkipDir }
+      return nil
+    }
+    if !info.IsDir() { files = append(files, p) }
+    return nil
+  })
+  if err != nil { return "", err }
+  sort.Strings(files)
+  h := sha256.New()
+  for _, p := range files {
+    rel, _ := filepath.Rel(dir, p)
+    info, err := os.Lstat(p)
+    if err != nil { return "", err }
+    fmt.Fprintf(h, "%s\x00%o\x00", filepath.ToSlash(rel), info.Mode())
+    if info.Mode()&os.ModeSymlink != 0 {
+      target, _ := os.Readlink(p)
+      io.WriteString(h, target)
+      continue
+    }
+    f, err := os.Open(p)
+    if err != nil { return "", err }
+    _, err = io.Copy(h, f)
+    f.Close()
+    if err != nil { return "", err }
+  }
+  return hex.EncodeToString(h.Sum(nil)[:20]), nil
+}
+
+// localStoreRef is the store directory of a file: node ref: file-<hash>
+// plus any peer suffix.
+func localStoreRef(ref string) string {
+  v := refVersion(ref)
+  _, _, hash := splitLocal(v)
+  return "file-" + hash + ref[len(v):]
+}
+
+// fetchLocalToStore packs a file: directory into dest, or extracts a file:
+// tarball there.
+func fetchLocalToStore(version, dest string) error {
+  _, p, _ := splitLocal(version)
+  fi, err := os.Stat(p)
+  if err != nil { return err }
+  if fi.IsDir() { return packDir(p, dest) }
+  return downloadAndExtractFromFile(p, dest)
+}
+
+// localRefPattern matches the local node versions inside lockfile keys,
+// versions and refs.
+var localRefPattern = regexp.MustCompile(`(^|@)(file:|link:)([^()#]*)(#[0-9a-f]*)?`)
+
+// localizeLockfile rewrites the local versions of lf between the on-disk form
+// (paths relative to projectDir, no hash) and the in-memory one (absolute
+// paths, file: hashes of the current content).
+func localizeLockfile(lf *LockFile, projectDir string, toDisk bool) {
+  base, err := filepath.Abs(projectDir)
+  if err != nil { base = projectDir }
+  resolved := map[string]string{}
+  rewrite := func(s string) string {
+    return localRefPattern.ReplaceAllStringFunc(s, func(m string) string {
+      sub := localRefPattern.FindStringSubmatch(m)
+      lead, prefix, p := sub[1], sub[2], filepath.FromSlash(sub[3])
+      if toDisk {
+        if rel, err := filepath.Rel(base, p); err == nil { p = rel }
+        return lead + prefix + filepath.ToSlash(p)
+      }
+      if !filepath.IsAbs(p) { p = filepath.Join(base, p) }
+      if prefix == linkPrefix { return lead + prefix + p }
+      v, ok := resolved[p]
+      if !ok {
+        v = prefix + p
+        if r, _, err := resolveLocal(v); err == nil { v = r }
+        resolved[p] = v
+      }
+      return lead + v
+    })
+  }
+  rewriteAll := func(list []string) []string {
+    out := make([]string, len(list))
+    for i, s := range list { out[i] = rewrite(s) }
+    return out
+  }
+  rewriteMap := func(m map[string]string) map[string]string {
+    if m == nil { return nil }
+    out := make(map[string]string, len(m))
+    for k, v := range m { out[k] = rewrite(v) }
+    return out
+  }
+  lf.Roots = rewriteAll(lf.Roots)
+  importers := make(map[string]LockImporter, len(lf.Importers))
+  for d, imp := range lf.Importers {
+    imp.Roots = rewriteAll(imp.Roots)
+    importers[d] = imp
+  }
+  if lf.Importers != nil { lf.Importers = importers }
+  packages := make(map[string]LockPackage, len(lf.Packages))
+  for k, lp := range lf.Packages {
+    lp.Version = rewrite(lp.Version)
+    lp.Dependencies = rewriteMap(lp.Dependencies)
+    lp.Peers = rewriteMap(lp.Peers)
+    packages[rewrite(k)] = lp
+  }
+  lf.Packages = packages
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+  "os"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+)
+
+func TestInstallLocalDependencies(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t, testPkg{Name: "a", Version: "1.0.0"})
+  root := t.TempDir()
+  proj := filepath.Join(root, "app")
+  writeTestFile(t, filepath.Join(root, "lib", "package.json"), `{"name":"lib","version":"0.1.0","dependencies":{"a":"1.0.0","util":"file:../util"}}`)
+  writeTestFile(t, filepath.Join(root, "util", "package.json"), `{"name":"util","version":"0.0.1"}`)
+  writeTestFile(t, filepath.Join(root, "linked", "package.json"), `{"name":"linked","version":"3.0.0"}`)
+  writeTestFile(t, filepath.Join(proj, "vendor", "tool.tgz"), string(buildTarball(t, map[string]any{"name": "tool", "version": "2.0.0"}, map[string]string{"index.js": ""})))
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"lib":"file:../lib","tool":"file:./vendor/tool.tgz","linked":"link:../linked"}}`)
+
+  runCLI(t, "install", "--dir", proj)
+  store, _ := defaultStoreDir()
+  libLink, err := os.Readlink(filepath.Join(proj, "node_modules", "lib"))
+  if err != nil || !strings.HasPrefix(libLink, filepath.Join(store, "lib", "file-")) { t.Fatalf("lib -> %s, %v", libLink, err) }
+  for _, dep := range []string{"a", "util"} {
+    if _, err := os.Stat(filepath.Join(libLink, "node_modules", dep, "package.json")); err != nil { t.Fatalf("lib dependency %s: %v", dep, err) }
+  }
+  if _, err := os.Stat(filepath.Join(proj, "node_modules", "tool", "index.js")); err != nil { t.Fatalf("tarball not extracted: %v", err) }
+  if got, _ := os.Readlink(filepath.Join(proj, "node_modules", "linked")); got != filepath.Join(root, "linked") { t.Fatalf("linked -> %s", got) }
+  if _, err := os.Stat(filepath.Join(root, "linked", "node_modules")); !os.IsNotExist(err) { t.Fatalf("linked directory should be left alone") }
+
+  b, _ := os.ReadFile(filepath.Join(proj, "wlim.lock"))
+  for _, want := range []string{`"lib@file:../lib"`, `"util@file:../util"`, `"tool@file:vendor/tool.tgz"`, `"linked@link:../linked"`} {
+    if !strings.Contains(string(b), want) { t.Fatalf("lockfile lacks %s:\n%s", want, b) }
+  }
+  if strings.Contains(string(b), root) { t.Fatalf("lockfile should use relative paths:\n%s", b) }
+
+  // changed content gets a new store entry while the lockfile is reused
+  writeTestFile(t, filepath.Join(root, "lib", "index.js"), "changed")
+  runCLI(t, "install", "--dir", proj)
+  if got, _ := os.Readlink(filepath.Join(proj, "node_modules", "lib")); got == libLink { t.Fatalf("lib should point at the new content") }
+  if _, err := os.Stat(filepath.Join(proj, "node_modules", "lib", "index.js")); err != nil { t.Fatalf("new content not installed: %v", err) }
+}
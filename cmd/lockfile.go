@@ -0,0 +1,117 @@
+package cmd
+
+import (
+  "encoding/base64"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+)
+
+// lockfileVersion is the wlim.lock schema this binary reads and writes.
+// Version 1 (no lockfileVersion field) recorded the graph and root specs;
+// version 2 adds resolved/integrity, engines and the dev/peer flags of every
+// package, so installs from the lockfile need no packuments, and guarantees
+// a spec for every root.
+const lockfileVersion = 2
+
+// migrateLockfile upgrades lf, as read from disk, to lockfileVersion. Roots
+// of version 1 lockfiles without a spec get their locked version; resolved
+// and integrity are filled in from the registry when the lockfile is next
+// written.
+func migrateLockfile(lf *LockFile) error {
+  if lf.LockfileVersion > lockfileVersion {
+    return fmt.Errorf("wlim.lock has lockfileVersion %d, but this wlim only supports up to %d; upgrade wlim", lf.LockfileVersion, lockfileVersion)
+  }
+  if lf.LockfileVersion == lockfileVersion { return nil }
+  logf("Migrating wlim.lock to lockfileVersion %d\n", lockfileVersion)
+  lf.RootSpecs = completeRootSpecs(lf.Roots, lf.RootSpecs)
+  for dir, li := range lf.Importers {
+    li.RootSpecs = completeRootSpecs(li.Roots, li.RootSpecs)
+    lf.Importers[dir] = li
+  }
+  markLockTypes(lf)
+  lf.LockfileVersion = lockfileVersion
+  return nil
+}
+
+// completeRootSpecs returns specs with a spec (its locked version when none
+// was recorded) and a section (dependencies by default) for every root.
+func completeRootSpecs(roots []string, specs map[string]LockRoot) map[string]LockRoot {
+  if len(roots) == 0 { return specs }
+  out := make(map[string]LockRoot, len(specs)+len(roots))
+  for name, rs := range specs { out[name] = rs }
+  for _, r := range roots {
+    name, ref := splitKey(r)
+    rs, ok := out[name]
+    if !ok { rs.Spec = refVersion(ref) }
+    if rs.Section == "" { rs.Section = sectionProd }
+    out[name] = rs
+  }
+  return out
+}
+
+// markLockTypes sets the dev and peer flags of lf's packages: dev ones are
+// only reached from devDependencies roots, peer ones only through peer
+// dependencies, i.e. edges named among the dependent's resolved peers.
+func markLockTypes(lf *LockFile) {
+  var prod, all []string
+  add := func(roots []string, specs map[string]LockRoot) {
+    for _, r := range roots {
+      name, _ := splitKey(r)
+      all = append(all, r)
+      if specs[name].Section != sectionDev { prod = append(prod, r) }
+    }
+  }
+  add(lf.Roots, lf.RootSpecs)
+  for _, li := range lf.Importers { add(li.Roots, li.RootSpecs) }
+  reach := func(from []string, viaPeers bool) map[string]bool {
+    seen := map[string]bool{}
+    queue := append([]string{}, from...)
+    for len(queue) > 0 {
+      k := queue[0]
+      queue = queue[1:]
+      lp, ok := lf.Packages[k]
+      if seen[k] || !ok { continue }
+      seen[k] = true
+      for name, ref := range lp.Dependencies {
+        if _, isPeer := lp.Peers[name]; isPeer && !viaPeers { continue }
+        queue = append(queue, keyOf(name, ref))
+      }
+    }
+    return seen
+  }
+  nonDev, nonPeer := reach(prod, true), reach(all, false)
+  for k, lp := range lf.Packages {
+    lp.Dev, lp.Peer = !nonDev[k], !nonPeer[k]
+    lf.Packages[k] = lp
+  }
+}
+
+// metadata rebuilds what installing a registry or tarball URL package needs
+// from its lockfile entry. It returns nil for entries without resolved and
+// integrity (not yet migrated), for tarball URLs locked without their
+// package.json version and for git and local versions, which are read from
+// their source.
+func (lp LockPackage) metadata() *PackageMetadata {
+  if lp.Resolved == "" || lp.Integrity == "" { return nil }
+  version := lp.Version
+  if isTarballURL(lp.Version) {
+    version = lp.PackageVersion
+  } else if isSourceSpec(lp.Version) {
+    return nil
+  }
+  if version == "" { return nil }
+  md := &PackageMetadata{Name: lp.Name, Version: version, OS: lp.OS, CPU: lp.CPU, Libc: lp.Libc, Deprecated: deprecation(lp.Deprecated), Engines: lp.Engines}
+  if len(lp.Bundled) > 0 { md.BundleDependencies, _ = json.Marshal(lp.Bundled) }
+  md.Dist.Tarball, md.Dist.Integrity = lp.Resolved, lp.Integrity
+  return md
+}
+
+// lockIntegrity returns the SRI of md's tarball, converting a bare sha1
+// shasum for old packages that have no integrity.
+func lockIntegrity(md *PackageMetadata) string {
+  if md.Dist.Integrity != "" { return md.Dist.Integrity }
+  sum, err := hex.DecodeString(md.Dist.Shasum)
+  if err != nil || len(sum) == 0 { return "" }
+  return "sha1-" + base64.StdEncoding.EncodeToString(sum)
+}
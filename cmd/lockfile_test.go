@@ -0,0 +1,61 @@
+package cmd
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestLockfileMigration(t *testing.T) {
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "wlim.lock"), `{
+  "roots": ["a@1.0.0(p@1.0.0)", "d@1.0.0"],
+  "rootSpecs": {"d": {"spec": "^1.0.0", "section": "devDependencies"}},
+  "packages": {
+    "a@1.0.0(p@1.0.0)": {"name": "a", "version": "1.0.0", "dependencies": {"b": "1.0.0", "p": "1.0.0"}, "peers": {"p": "1.0.0"}},
+    "b@1.0.0": {"name": "b", "version": "1.0.0", "dependencies": {}},
+    "d@1.0.0": {"name": "d", "version": "1.0.0", "dependencies": {"b": "1.0.0", "e": "1.0.0"}},
+    "e@1.0.0": {"name": "e", "version": "1.0.0", "dependencies": {}},
+    "p@1.0.0": {"name": "p", "version": "1.0.0", "dependencies": {}}
+  }
+}`)
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatal(err) }
+  if lf.LockfileVersion != lockfileVersion { t.Errorf("lockfileVersion = %d", lf.LockfileVersion) }
+  if rs := lf.RootSpecs["a"]; rs.Spec != "1.0.0" || rs.Section != sectionProd { t.Errorf("a root spec: %+v", rs) }
+  if rs := lf.RootSpecs["d"]; rs.Spec != "^1.0.0" || rs.Section != sectionDev { t.Errorf("d root spec: %+v", rs) }
+  for k, want := range map[string][2]bool{"a@1.0.0(p@1.0.0)": {false, false}, "b@1.0.0": {false, false}, "d@1.0.0": {true, false}, "e@1.0.0": {true, false}, "p@1.0.0": {false, true}} {
+    if lp := lf.Packages[k]; lp.Dev != want[0] || lp.Peer != want[1] { t.Errorf("%s: dev=%v peer=%v, want %v", k, lp.Dev, lp.Peer, want) }
+  }
+
+  writeTestFile(t, filepath.Join(proj, "wlim.lock"), `{"lockfileVersion": 99, "roots": [], "packages": {}}`)
+  if _, err := readLockfile(proj); err == nil || !strings.Contains(err.Error(), "lockfileVersion 99") || !strings.Contains(err.Error(), "upgrade wlim") { t.Fatalf("newer lockfile: %v", err) }
+}
+
+func TestFrozenInstallWithoutRegistry(t *testing.T) {
+  srv := newTestRegistry(t,
+    testPkg{Name: "b", Version: "1.0.0", Extra: map[string]any{"os": []string{"!aix"}, "engines": map[string]string{"node": ">=8"}}},
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"b": "^1.0.0"}},
+    testPkg{Name: "t", Version: "2.0.0"},
+  )
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"a":"^1.0.0"},"devDependencies":{"t":"^2.0.0"}}`)
+  runCLI(t, "install", "--dir", proj)
+  lf := lockHas(t, proj, "a@1.0.0", "b@1.0.0", "t@2.0.0")
+  b := lf.Packages["b@1.0.0"]
+  if lf.LockfileVersion != lockfileVersion || b.Resolved != srv.URL+"/-/b-1.0.0.tgz" || !strings.HasPrefix(b.Integrity, "sha512-") || b.Engines["node"] != ">=8" || len(b.OS) != 1 {
+    t.Fatalf("lockfile entry: %d %+v", lf.LockfileVersion, b)
+  }
+  if !lf.Packages["t@2.0.0"].Dev || lf.Packages["b@1.0.0"].Dev { t.Fatalf("dev flags: %+v", lf.Packages) }
+  if rs := lf.RootSpecs["t"]; rs.Spec != "^2.0.0" || rs.Section != sectionDev { t.Fatalf("root spec: %+v", rs) }
+
+  // with the store warm, a frozen install needs neither packuments nor tarballs
+  srv.Close()
+  t.Setenv("WLIM_CACHE_DIR", t.TempDir())
+  if _, _, err := nodesFromLock(context.Background(), lf, map[string]*RootDoc{}); err != nil { t.Fatalf("nodesFromLock offline: %v", err) }
+  if err := os.RemoveAll(filepath.Join(proj, "node_modules")); err != nil { t.Fatal(err) }
+  runCLI(t, "install", "--dir", proj, "--frozen-lockfile")
+  if _, err := os.Stat(filepath.Join(proj, "node_modules", "a", "node_modules", "b", "package.json")); err != nil { t.Fatalf("not installed: %v", err) }
+}
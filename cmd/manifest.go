@@ -0,0 +1,158 @@
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+
+  "github.com/spf13/cobra"
+)
+
+// Dependency sections of a project's package.json that become lockfile roots.
+const (
+  sectionProd     = "dependencies"
+  sectionDev      = "devDependencies"
+  sectionOptional = "optionalDependencies"
+  // peerDependencies are written by `wlim add --save-peer` but never become roots
+  sectionPeer = "peerDependencies"
+)
+
+// projectManifest is the subset of the project's own package.json wlim reads.
+type projectManifest struct {
+  Name                 string            `json:"name"`
+  Version              string            `json:"version"`
+  Dependencies         map[string]string `json:"dependencies"`
+  DevDependencies      map[string]string `json:"devDependencies"`
+  OptionalDependencies map[string]string `json:"optionalDependencies"`
+  Workspaces           json.RawMessage   `json:"workspaces"` // globs, or {"packages": globs}
+  Overrides            json.RawMessage   `json:"overrides"`  // npm overrides, see overrides()
+  Resolutions          map[string]string `json:"resolutions"` // yarn resolutions
+}
+
+// rootDep is a direct dependency of the project and the section it came from.
+type rootDep struct {
+  Name    string
+  Spec    string
+  Section string
+  Link    string // set for sibling workspace packages, see workspaceLink
+  Dir     string // directory relative file:/link: specs resolve against
+}
+
+func readProjectManifest(projectDir string) (*projectManifest, error) {
+  b, err := os.ReadFile(filepath.Join(projectDir, "package.json"))
+  if err != nil { return nil, err }
+  var m projectManifest
+  if err := json.Unmarshal(b, &m); err != nil { return nil, err }
+  return &m, nil
+}
+
+// rootDeps flattens the dependency sections into a sorted list of roots.
+// Like npm, optionalDependencies override dependencies and both win over
+// devDependencies when a name is listed more than once.
+func (m *projectManifest) rootDeps() []rootDep {
+  byName := make(map[string]rootDep)
+  for name, spec := range m.DevDependencies { byName[name] = rootDep{Name: name, Spec: spec, Section: sectionDev} }
+  for name, spec := range m.Dependencies { byName[name] = rootDep{Name: name, Spec: spec, Section: sectionProd} }
+  for name, spec := range m.OptionalDependencies { byName[name] = rootDep{Name: name, Spec: spec, Section: sectionOptional} }
+  deps := make([]rootDep, 0, len(byName))
+  for _, d := range byName { deps = append(deps, d) }
+  sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+  return deps
+}
+
+// replaceRootDep returns deps with d in place of the root of the same name,
+// which keeps its section, or with d added.
+func replaceRootDep(deps []rootDep, d rootDep) []rootDep {
+  for i := range deps {
+    if deps[i].Name != d.Name { continue }
+    d.Section = deps[i].Section
+    deps[i] = d
+    return deps
+  }
+  return append(deps, d)
+}
+
+// lockfileMatchesManifest reports whether lf was resolved from exactly deps,
+// so an install can reuse it instead of resolving again.
+func lockfileMatchesManifest(lf *LockFile, deps []rootDep) bool {
+  return lockRootsMatch(lf.Roots, lf.RootSpecs, deps)
+}
+
+func lockRootsMatch(roots []string, rootSpecs map[string]LockRoot, deps []rootDep) bool {
+  if len(rootSpecs) != len(deps) { return false }
+  locked := make(map[string]bool, len(roots))
+  for _, r := range roots {
+    name, _ := splitKey(r)
+    locked[name] = true
+  }
+  for _, d := range deps {
+    rs, ok := rootSpecs[d.Name]
+    if !ok || rs.Spec != d.Spec || rs.Section != d.Section || rs.Link != d.Link { return false }
+    if d.Link == "" && !locked[d.Name] { return false }
+  }
+  return true
+}
+
+// sectionFilter selects which root sections an install links into the
+// project. The lockfile always keeps every section.
+type sectionFilter struct {
+  prodOnly   bool
+  devOnly    bool
+  noOptional bool
+}
+
+func addSectionFlags(cmd *cobra.Command) {
+  cmd.Flags().Bool("prod", false, "Skip devDependencies")
+  cmd.Flags().Bool("dev", false, "Only install devDependencies")
+  cmd.Flags().Bool("no-optional", false, "Skip optionalDependencies")
+}
+
+// sectionFilterFromFlags reads the flags of addSectionFlags. --prod and --dev
+// together would install nothing, so they are rejected.
+func sectionFilterFromFlags(cmd *cobra.Command) (sectionFilter, error) {
+  var f sectionFilter
+  f.prodOnly, _ = cmd.Flags().GetBool("prod")
+  f.devOnly, _ = cmd.Flags().GetBool("dev")
+  f.noOptional, _ = cmd.Flags().GetBool("no-optional")
+  if f.prodOnly && f.devOnly { return f, fmt.Errorf("--prod and --dev are mutually exclusive") }
+  return f, nil
+}
+
+func (f sectionFilter) includes(section string) bool {
+  if section == "" { section = sectionProd } // roots from lockfiles without specs
+  switch {
+  case f.prodOnly && section == sectionDev:
+    return false
+  case f.devOnly && section != sectionDev:
+    return false
+  case f.noOptional && section == sectionOptional:
+    return false
+  }
+  return true
+}
+
+// split partitions roots into the ones to link and the ones to leave out.
+func (f sectionFilter) split(roots []*GraphNode, rootSpecs map[string]LockRoot) (kept, skipped []*GraphNode) {
+  for _, r := range roots {
+    if f.includes(rootSpecs[r.Name].Section) {
+      kept = append(kept, r)
+    } else {
+      skipped = append(skipped, r)
+    }
+  }
+  return kept, skipped
+}
+
+// nodes returns the part of the graph to install for roots; --no-optional
+// also leaves out transitive optional packages.
+func (f sectionFilter) nodes(roots []*GraphNode, all map[string]*GraphNode) map[string]*GraphNode {
+  out := reachableNodes(roots, all)
+  if f.noOptional {
+    for k, n := range out {
+      if n.Optional { delete(out, k) }
+    }
+  }
+  return out
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+  "strings"
+  "testing"
+
+  "github.com/spf13/cobra"
+)
+
+func TestManifestRootDepsPrecedence(t *testing.T) {
+  m := &projectManifest{
+    Dependencies:         map[string]string{"x": "1.0.0", "y": "1.0.0"},
+    DevDependencies:      map[string]string{"x": "2.0.0", "z": "1.0.0"},
+    OptionalDependencies: map[string]string{"y": "3.0.0"},
+  }
+  got := map[string]rootDep{}
+  for _, d := range m.rootDeps() { got[d.Name] = d }
+  if got["x"].Section != sectionProd || got["x"].Spec != "1.0.0" { t.Fatalf("x: %+v", got["x"]) }
+  if got["y"].Section != sectionOptional || got["y"].Spec != "3.0.0" { t.Fatalf("y: %+v", got["y"]) }
+  if got["z"].Section != sectionDev { t.Fatalf("z: %+v", got["z"]) }
+}
+
+func TestSectionFilterSplit(t *testing.T) {
+  roots := []*GraphNode{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "legacy"}}
+  specs := map[string]LockRoot{
+    "a": {Spec: "1", Section: sectionProd},
+    "b": {Spec: "1", Section: sectionDev},
+    "c": {Spec: "1", Section: sectionOptional},
+  }
+  names := func(ns []*GraphNode) (out []string) { for _, n := range ns { out = append(out, n.Name) }; return }
+  kept, skipped := sectionFilter{prodOnly: true, noOptional: true}.split(roots, specs)
+  if got := names(kept); len(got) != 2 || got[0] != "a" || got[1] != "legacy" { t.Fatalf("kept: %v", got) }
+  if got := names(skipped); len(got) != 2 { t.Fatalf("skipped: %v", got) }
+  kept, _ = sectionFilter{devOnly: true}.split(roots, specs)
+  if got := names(kept); len(got) != 1 || got[0] != "b" { t.Fatalf("dev kept: %v", got) }
+}
+
+func TestSectionFilterFromFlags(t *testing.T) {
+  cmd := &cobra.Command{}
+  addSectionFlags(cmd)
+  _ = cmd.Flags().Set("prod", "true")
+  if f, err := sectionFilterFromFlags(cmd); err != nil || !f.prodOnly || f.devOnly { t.Fatalf("--prod: %+v %v", f, err) }
+  _ = cmd.Flags().Set("dev", "true")
+  if _, err := sectionFilterFromFlags(cmd); err == nil || !strings.Contains(err.Error(), "mutually exclusive") { t.Fatalf("--prod --dev: %v", err) }
+}
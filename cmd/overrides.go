@@ -0,0 +1,154 @@
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "sort"
+  "strings"
+)
+
+// Overrides replace the spec of transitive dependencies. package.json
+// "overrides" (npm, nested objects select dependencies of a package, "."
+// the package itself, "$name" the project's own spec for name) and
+// "resolutions" (yarn globs such as "**/minimist" or "a/b") are flattened
+// into selectors like "**/foo/**/bar" -> spec, the form kept in wlim.lock.
+// A selector segment may carry a range ("foo@^1"); on the last segment it
+// matches when the version that would otherwise be picked is in the range.
+// Direct dependencies keep the spec from package.json.
+
+// overrides flattens the overrides and resolutions of m; overrides win when
+// both select the same path.
+func (m *projectManifest) overrides() (map[string]string, error) {
+  out := map[string]string{}
+  for key, spec := range m.Resolutions {
+    sel := key
+    if n := strings.Count(key, "/"); n == 0 || (n == 1 && strings.HasPrefix(key, "@")) { sel = "**/" + key }
+    out[sel] = spec
+  }
+  if len(m.Overrides) > 0 {
+    var raw map[string]json.RawMessage
+    if err := json.Unmarshal(m.Overrides, &raw); err != nil { return nil, fmt.Errorf("overrides: %w", err) }
+    refs := map[string]string{}
+    for _, d := range m.rootDeps() { refs[d.Name] = d.Spec }
+    if err := flattenOverrides("", raw, refs, out); err != nil { return nil, fmt.Errorf("overrides: %w", err) }
+  }
+  if len(out) == 0 { return nil, nil }
+  return out, nil
+}
+
+func flattenOverrides(prefix string, raw map[string]json.RawMessage, refs map[string]string, out map[string]string) error {
+  for key, val := range raw {
+    sel := prefix + "**/" + key
+    if key == "." {
+      if prefix == "" { return fmt.Errorf(`"." must be inside a package's overrides`) }
+      sel = strings.TrimSuffix(prefix, "/")
+    }
+    var spec string
+    if err := json.Unmarshal(val, &spec); err == nil {
+      if ref, ok := strings.CutPrefix(spec, "$"); ok {
+        if spec, ok = refs[ref]; !ok { return fmt.Errorf("%s: %q is not a dependency of the project", key, "$"+ref) }
+      }
+      out[sel] = spec
+      continue
+    }
+    var nested map[string]json.RawMessage
+    if key == "." || json.Unmarshal(val, &nested) != nil { return fmt.Errorf("%s: expected a spec or an object", key) }
+    if err := flattenOverrides(sel+"/", nested, refs, out); err != nil { return err }
+  }
+  return nil
+}
+
+// overrideSeg is one selector segment: "**", or a name with an optional range.
+type overrideSeg struct {
+  any  bool
+  name string
+  rng  npmRange
+}
+
+type overrideRule struct {
+  selector string
+  segs     []overrideSeg // the last one selects the dependency itself
+  spec     string
+}
+
+// parseOverrides compiles flattened overrides; local specs resolve against
+// base, the project directory.
+func parseOverrides(overrides map[string]string, base string) ([]*overrideRule, error) {
+  var rules []*overrideRule
+  for sel, spec := range overrides {
+    rule := &overrideRule{selector: sel, spec: absLocalSpec(spec, base)}
+    parts := strings.Split(sel, "/")
+    for i := 0; i < len(parts); i++ {
+      p := parts[i]
+      if strings.HasPrefix(p, "@") && i+1 < len(parts) {
+        i++
+        p += "/" + parts[i]
+      }
+      if p == "**" {
+        rule.segs = append(rule.segs, overrideSeg{any: true})
+        continue
+      }
+      name, rng := splitKey(p)
+      seg := overrideSeg{name: name}
+      if name == "" { return nil, fmt.Errorf("override %q: empty package name", sel) }
+      if rng != "" {
+        r, err := parseRange(rng)
+        if err != nil { return nil, fmt.Errorf("override %q: %w", sel, err) }
+        seg.rng = r
+      }
+      rule.segs = append(rule.segs, seg)
+    }
+    if len(rule.segs) == 0 || rule.segs[len(rule.segs)-1].any { return nil, fmt.Errorf("override %q must end with a package name", sel) }
+    rules = append(rules, rule)
+  }
+  // the most specific selector wins
+  sort.Slice(rules, func(i, j int) bool {
+    si, sj := rules[i].specificity(), rules[j].specificity()
+    if si != sj { return si > sj }
+    return rules[i].selector < rules[j].selector
+  })
+  return rules, nil
+}
+
+func (o *overrideRule) specificity() int {
+  n := 0
+  for _, s := range o.segs {
+    if !s.any { n++ }
+    if s.rng != nil { n++ }
+  }
+  return n
+}
+
+func (o *overrideRule) target() overrideSeg { return o.segs[len(o.segs)-1] }
+
+// matches reports whether the rule selects dependency name of the node at
+// the end of chain (keys from the root down); the target's range is checked
+// by the caller.
+func (o *overrideRule) matches(chain []string, name string) bool {
+  if o.target().name != name { return false }
+  return matchSegs(o.segs[:len(o.segs)-1], chain)
+}
+
+func matchSegs(segs []overrideSeg, chain []string) bool {
+  if len(segs) == 0 { return len(chain) == 0 }
+  if segs[0].any { return matchSegs(segs[1:], chain) || (len(chain) > 0 && matchSegs(segs, chain[1:])) }
+  if len(chain) == 0 { return false }
+  name, version := splitKey(chain[0])
+  if name != segs[0].name || (segs[0].rng != nil && !rangeHas(segs[0].rng, version)) { return false }
+  return matchSegs(segs[1:], chain[1:])
+}
+
+// rangeHas reports whether the registry version of a node version is in r.
+func rangeHas(r npmRange, version string) bool {
+  if isSourceSpec(version) { return false }
+  v, err := parseVersion(realVersion(refVersion(version)))
+  return err == nil && r.test(v)
+}
+
+// override returns the rule for dependency name of the node reached by chain.
+func (r *resolver) override(chain []string, name string) *overrideRule {
+  for _, o := range r.overrides {
+    if o.matches(chain, name) { return o }
+  }
+  return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+  "encoding/json"
+  "maps"
+  "path/filepath"
+  "testing"
+)
+
+func TestFlattenOverrides(t *testing.T) {
+  var m projectManifest
+  err := json.Unmarshal([]byte(`{
+    "dependencies": {"baz": "^3.0.0"},
+    "overrides": {"foo": {".": "1.0.0", "bar": "2.0.0"}, "baz": "$baz", "@s/q": "1"},
+    "resolutions": {"**/minimist": "1.2.6", "a/b": "1", "@s/p": "2", "@s/q": "9"}
+  }`), &m)
+  if err != nil { t.Fatal(err) }
+  got, err := m.overrides()
+  if err != nil { t.Fatal(err) }
+  want := map[string]string{"**/foo": "1.0.0", "**/foo/**/bar": "2.0.0", "**/baz": "^3.0.0", "**/@s/q": "1", "**/minimist": "1.2.6", "a/b": "1", "**/@s/p": "2"}
+  if !maps.Equal(got, want) { t.Fatalf("got %v, want %v", got, want) }
+
+  m.Overrides = json.RawMessage(`{"bar": "$nope"}`)
+  if _, err := m.overrides(); err == nil { t.Errorf("unknown $reference should fail") }
+}
+
+func TestOverrideSelectors(t *testing.T) {
+  rules, err := parseOverrides(map[string]string{"**/foo@^1/bar": "2.0.0", "**/@s/p/**/bar": "3.0.0", "**/bar": "1.0.0"}, "")
+  if err != nil { t.Fatal(err) }
+  res := &resolver{overrides: rules}
+  cases := []struct {
+    chain []string
+    want  string
+  }{
+    {[]string{"app@1.0.0", "foo@1.2.0"}, "2.0.0"},
+    {[]string{"foo@2.0.0"}, "1.0.0"},
+    {[]string{"foo@1.2.0", "x@1.0.0"}, "1.0.0"},
+    {[]string{"@s/p@1.0.0", "x@1.0.0", "y@1.0.0"}, "3.0.0"},
+  }
+  for _, c := range cases {
+    if o := res.override(c.chain, "bar"); o == nil || o.spec != c.want { t.Errorf("%v: got %+v, want %s", c.chain, o, c.want) }
+  }
+  if o := res.override([]string{"foo@1.2.0"}, "baz"); o != nil { t.Errorf("baz should not be overridden: %+v", o) }
+  if _, err := parseOverrides(map[string]string{"foo/**": "1"}, ""); err == nil { t.Errorf("selector ending in ** should fail") }
+}
+
+func TestInstallOverrides(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "minimist", Version: "1.2.5"},
+    testPkg{Name: "minimist", Version: "1.2.6"},
+    testPkg{Name: "minimist", Version: "1.2.8"},
+    testPkg{Name: "bar", Version: "1.0.0"},
+    testPkg{Name: "bar", Version: "2.0.0"},
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"minimist": "^1.2.0", "bar": "^1.0.0"}},
+    testPkg{Name: "foo", Version: "1.0.0", Deps: map[string]string{"bar": "^1.0.0"}},
+  )
+  proj := t.TempDir()
+  manifest := func(minimist string) {
+    writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"a":"1.0.0","foo":"1.0.0","minimist":"^1.2.7"},
+      "overrides":{"foo":{"bar":"2.0.0"}},"resolutions":{"**/minimist":"`+minimist+`"}}`)
+  }
+  manifest("1.2.6")
+  runCLI(t, "install", "--dir", proj)
+  lf := lockHas(t, proj, "a@1.0.0", "foo@1.0.0", "minimist@1.2.6", "minimist@1.2.8", "bar@1.0.0", "bar@2.0.0")
+  if d := lf.Packages["a@1.0.0"].Dependencies; d["minimist"] != "1.2.6" || d["bar"] != "1.0.0" { t.Fatalf("a deps: %v", d) }
+  if d := lf.Packages["foo@1.0.0"].Dependencies; d["bar"] != "2.0.0" { t.Fatalf("foo deps: %v", d) }
+  if lf.Overrides["**/minimist"] != "1.2.6" || lf.Overrides["**/foo/**/bar"] != "2.0.0" { t.Fatalf("overrides not locked: %v", lf.Overrides) }
+
+  // a changed override invalidates the lockfile
+  manifest("1.2.5")
+  runCLI(t, "install", "--dir", proj)
+  lf = lockHas(t, proj, "minimist@1.2.5")
+  if d := lf.Packages["a@1.0.0"].Dependencies; d["minimist"] != "1.2.5" { t.Fatalf("a deps after change: %v", d) }
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "sort"
+  "strings"
+)
+
+// autoInstallPeers resolves missing (non-optional) peers and provides them
+// at the project level, like pnpm's auto-install-peers.
+var autoInstallPeers bool
+
+// PeerIssue is a peer dependency that could not be satisfied as declared.
+// The resolver backtracks on incompatible ones; the rest are printed as
+// warnings.
+type PeerIssue struct {
+  Kind    string   // "missing" or "incompatible"
+  Package string   // name@version declaring the peer
+  Peer    string
+  Wanted  string
+  Found   string   // version provided, for incompatible peers
+  Path    []string // dependency chain from the project
+}
+
+func (i PeerIssue) String() string {
+  via := strings.Join(i.Path, " > ")
+  if i.Kind == "missing" {
+    return fmt.Sprintf("missing peer %s@%s required by %s (via %s)", i.Peer, i.Wanted, i.Package, via)
+  }
+  return fmt.Sprintf("incompatible peer %s@%s (found %s) required by %s (via %s)", i.Peer, i.Wanted, i.Found, i.Package, via)
+}
+
+func printPeerIssues(issues []PeerIssue) {
+  for _, i := range issues { fmt.Println("Warning:", i.String()) }
+}
+
+// peerSuffix renders resolved peers the way pnpm names store instances:
+// (a@1.0.0)(b@2.0.0), sorted by name.
+func peerSuffix(peers map[string]string) string {
+  if len(peers) == 0 { return "" }
+  names := make([]string, 0, len(peers))
+  for n := range peers { names = append(names, n) }
+  sort.Strings(names)
+  var b strings.Builder
+  for _, n := range names { b.WriteString("(" + n + "@" + peers[n] + ")") }
+  return b.String()
+}
+
+// refVersion strips a peer suffix from a dependency reference.
+func refVersion(ref string) string {
+  v, _, _ := strings.Cut(ref, "(")
+  return v
+}
+
+// versionSatisfies reports whether version is within the range spec.
+func versionSatisfies(version, spec string) bool {
+  if spec == "" || spec == "*" || spec == version { return true }
+  r, err := parseRange(spec)
+  if err != nil { return false }
+  v, err := parseVersion(version)
+  if err != nil { return false }
+  return r.test(v)
+}
+
+// scopeEntry is one package visible in a node_modules level while peers are
+// resolved. ref becomes final once the entry has been visited.
+type scopeEntry struct {
+  node   *GraphNode // base node (no peers applied)
+  ref    string
+  ext    map[string]*scopeEntry // peers resolved from outside this entry's subtree
+  state  int                    // 0 pending, 1 visiting, 2 done
+  scopes []map[string]*scopeEntry // levels visible to the entry, its own level last
+  path   []string
+}
+
+type peerResolver struct {
+  base      map[string]*GraphNode
+  out       map[string]*GraphNode
+  peerNames map[string][]string // base key -> peer names used anywhere in its subtree
+  memo      map[string]peerResult
+  active    map[string]bool
+  issues    []PeerIssue
+  reported  map[string]bool
+  missing   map[string]string // peer name -> first wanted range
+}
+
+type peerResult struct {
+  ref string
+  ext map[string]*scopeEntry
+}
+
+// resolvePeers turns a graph resolved without peers into one where every
+// package sees the peers provided by its nearest ancestor. A package@version
+// that ends up with different peers gets one instance per peer set, keyed as
+// name@version(peer@version). Missing and incompatible peers are returned as
+// issues; with autoInstallPeers missing ones are resolved and provided at the
+// project level.
+func resolvePeers(ctx context.Context, roots []*GraphNode, nodes map[string]*GraphNode, cache map[string]*RootDoc, res *resolver) (map[string]*GraphNode, []*GraphNode, []PeerIssue, error) {
+  var extra []*GraphNode // auto-installed peers, visible to all but not project roots
+  for attempt := 0; ; attempt++ {
+    r := &peerResolver{base: nodes, out: map[string]*GraphNode{}, memo: map[string]peerResult{}, active: map[string]bool{}, reported: map[string]bool{}, missing: map[string]string{}}
+    r.computePeerNames()
+    level := map[string]*scopeEntry{}
+    scopes := []map[string]*scopeEntry{level}
+    for _, n := range append(append([]*GraphNode{}, extra...), roots...) {
+      level[n.Name] = &scopeEntry{node: n, ref: n.ref(), scopes: scopes, path: []string{keyOf(n.Name, n.Version)}}
+    }
+    r.visitLevel(level)
+    if !autoInstallPeers || len(r.missing) == 0 || attempt >= 5 {
+      newRoots := make([]*GraphNode, 0, len(roots))
+      for _, n := range roots { newRoots = append(newRoots, r.out[keyOf(n.Name, level[n.Name].ref)]) }
+      r.fixDanglingRefs()
+      return r.out, newRoots, r.issues, nil
+    }
+    names := make([]string, 0, len(r.missing))
+    for name := range r.missing { names = append(names, name) }
+    sort.Strings(names)
+    for _, name := range names {
+      sub, peer, err := resolveGraph(ctx, name, r.missing[name], cache, res)
+      if err != nil { return nil, nil, nil, fmt.Errorf("auto-install peer %s@%s: %w", name, r.missing[name], err) }
+      logf("Auto-installing peer %s@%s\n", peer.Name, peer.Version)
+      for k, n := range sub {
+        if _, ok := nodes[k]; !ok { nodes[k] = n }
+      }
+      extra = append(extra, nodes[keyOf(peer.Name, peer.Version)])
+    }
+  }
+}
+
+// computePeerNames collects, per base node, the peer names its subtree may
+// resolve from outside; subtrees without any are instantiated only once.
+func (r *peerResolver) computePeerNames() {
+  sets := make(map[string]map[string]bool, len(r.base))
+  for k, n := range r.base {
+    s := map[string]bool{}
+    if n.MD != nil {
+      for p := range n.MD.PeerDependencies { s[p] = true }
+    }
+    sets[k] = s
+  }
+  for changed := true; changed; {
+    changed = false
+    for k, n := range r.base {
+      for depName, depV := range n.Deps {
+        for p := range sets[keyOf(depName, depV)] {
+          if !sets[k][p] { sets[k][p] = true; changed = true }
+        }
+      }
+    }
+  }
+  r.peerNames = make(map[string][]string, len(sets))
+  for k, s := range sets {
+    names := make([]string, 0, len(s))
+    for p := range s { names = append(names, p) }
+    sort.Strings(names)
+    r.peerNames[k] = names
+  }
+}
+
+func lookupScope(scopes []map[string]*scopeEntry, name string) *scopeEntry {
+  for i := len(scopes) - 1; i >= 0; i-- {
+    if e, ok := scopes[i][name]; ok { return e }
+  }
+  return nil
+}
+
+func (r *peerResolver) visitLevel(level map[string]*scopeEntry) {
+  names := make([]string, 0, len(level))
+  for n := range level { names = append(names, n) }
+  sort.Strings(names)
+  for _, n := range names { r.ensure(level[n]) }
+}
+
+// ensure visits e unless it is already done or on the current path (a cycle,
+// where the plain version stands in until fixDanglingRefs).
+func (r *peerResolver) ensure(e *scopeEntry) {
+  if e.state != 0 { return }
+  e.state = 1
+  e.ref, e.ext = r.visit(e)
+  e.state = 2
+}
+
+func (r *peerResolver) visit(e *scopeEntry) (string, map[string]*scopeEntry) {
+  n := e.node
+  baseKey := keyOf(n.Name, n.Version)
+  parentScopes := e.scopes
+  // the memo key captures what the subtree can see from outside
+  var sig strings.Builder
+  sig.WriteString(baseKey)
+  for _, p := range r.peerNames[baseKey] {
+    sig.WriteString("|" + p + "=")
+    if pe := lookupScope(parentScopes, p); pe != nil {
+      if pe.state == 2 { sig.WriteString(pe.ref) } else { sig.WriteString("~" + keyOf(pe.node.Name, pe.node.Version)) }
+    }
+  }
+  memoKey := sig.String()
+  if res, ok := r.memo[memoKey]; ok { return res.ref, res.ext }
+  if r.active[baseKey] { return n.Version, nil }
+  r.active[baseKey] = true
+  defer delete(r.active, baseKey)
+
+  ext := map[string]*scopeEntry{}
+  deps := make(map[string]string, len(n.Deps))
+  // own peers come from the levels above, never from the package's own deps
+  if n.MD != nil {
+    peerNames := make([]string, 0, len(n.MD.PeerDependencies))
+    for p := range n.MD.PeerDependencies { peerNames = append(peerNames, p) }
+    sort.Strings(peerNames)
+    for _, p := range peerNames {
+      if _, isDep := n.Deps[p]; isDep || p == n.Name { continue }
+      wanted := n.MD.PeerDependencies[p]
+      pe := lookupScope(parentScopes, p)
+      if pe == nil {
+        if n.MD.PeerDependenciesMeta[p].Optional { continue }
+        r.report(PeerIssue{Kind: "missing", Package: baseKey, Peer: p, Wanted: wanted, Path: e.path})
+        if _, seen := r.missing[p]; !seen { r.missing[p] = wanted }
+        continue
+      }
+      r.ensure(pe)
+      if !versionSatisfies(pe.node.pkgVersion(), wanted) {
+        r.report(PeerIssue{Kind: "incompatible", Package: baseKey, Peer: p, Wanted: wanted, Found: pe.node.Version, Path: e.path})
+      }
+      ext[p] = pe
+      deps[p] = pe.ref
+    }
+  }
+  // children see this package's deps first
+  level := make(map[string]*scopeEntry, len(n.Deps))
+  childScopes := append(append([]map[string]*scopeEntry{}, parentScopes...), level)
+  for depName, depV := range n.Deps {
+    d, ok := r.base[keyOf(depName, depV)]
+    if !ok { continue }
+    level[depName] = &scopeEntry{node: d, ref: d.ref(), scopes: childScopes, path: append(append([]string{}, e.path...), keyOf(d.Name, d.Version))}
+  }
+  r.visitLevel(level)
+  for depName, ce := range level {
+    deps[depName] = ce.ref
+    for p, pe := range ce.ext {
+      if level[p] == pe { continue } // provided by this package's own deps
+      ext[p] = pe
+    }
+  }
+  peers := make(map[string]string, len(ext))
+  for p, pe := range ext { peers[p] = pe.ref }
+  inst := &GraphNode{Name: n.Name, Version: n.Version, MD: n.MD, Deps: deps, Optional: n.Optional}
+  if len(peers) > 0 { inst.Peers = peers }
+  if _, ok := r.out[inst.key()]; !ok { r.out[inst.key()] = inst }
+  r.memo[memoKey] = peerResult{ref: inst.ref(), ext: ext}
+  return inst.ref(), ext
+}
+
+func (r *peerResolver) report(i PeerIssue) {
+  k := i.Kind + "|" + i.Package + "|" + i.Peer
+  if r.reported[k] { return }
+  r.reported[k] = true
+  r.issues = append(r.issues, i)
+}
+
+// fixDanglingRefs points references taken inside a dependency cycle, which
+// used the plain version, at the instance that was created for it.
+func (r *peerResolver) fixDanglingRefs() {
+  keys := make([]string, 0, len(r.out))
+  for k := range r.out { keys = append(keys, k) }
+  sort.Strings(keys)
+  for _, n := range r.out {
+    for depName, ref := range n.Deps {
+      if _, ok := r.out[keyOf(depName, ref)]; ok { continue }
+      for _, k := range keys {
+        cand := r.out[k]
+        if cand.Name == depName && cand.Version == refVersion(ref) {
+          n.Deps[depName] = cand.ref()
+          break
+        }
+      }
+    }
+  }
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+)
+
+func peerTestNode(name, version string, deps, peers map[string]string) *GraphNode {
+  if deps == nil { deps = map[string]string{} }
+  return &GraphNode{Name: name, Version: version, Deps: deps, MD: &PackageMetadata{Name: name, Version: version, PeerDependencies: peers}}
+}
+
+func peerTestGraph(ns ...*GraphNode) map[string]*GraphNode {
+  m := map[string]*GraphNode{}
+  for _, n := range ns { m[keyOf(n.Name, n.Version)] = n }
+  return m
+}
+
+func TestResolvePeersSplitsInstancesByPeerSet(t *testing.T) {
+  react18 := peerTestNode("react", "18.0.0", nil, nil)
+  react17 := peerTestNode("react", "17.0.0", nil, nil)
+  dom := peerTestNode("react-dom", "18.0.0", nil, map[string]string{"react": "^18.0.0"})
+  legacy := peerTestNode("legacy", "1.0.0", map[string]string{"react": "17.0.0", "react-dom": "18.0.0"}, nil)
+  nodes := peerTestGraph(react18, react17, dom, legacy)
+
+  out, roots, issues, err := resolvePeers(context.Background(), []*GraphNode{react18, dom, legacy}, nodes, nil, nil)
+  if err != nil { t.Fatalf("resolvePeers: %v", err) }
+  if _, ok := out["react-dom@18.0.0(react@18.0.0)"]; !ok { t.Fatalf("missing react 18 instance: %v", keysOf(out)) }
+  if _, ok := out["react-dom@18.0.0(react@17.0.0)"]; !ok { t.Fatalf("missing react 17 instance: %v", keysOf(out)) }
+  if roots[1].key() != "react-dom@18.0.0(react@18.0.0)" { t.Fatalf("root react-dom: %s", roots[1].key()) }
+  // legacy provides react itself, so it needs no suffix
+  l := out["legacy@1.0.0"]
+  if l == nil || l.Deps["react-dom"] != "18.0.0(react@17.0.0)" { t.Fatalf("legacy deps: %+v", l) }
+  if out["react-dom@18.0.0(react@17.0.0)"].Deps["react"] != "17.0.0" { t.Fatalf("peer link missing") }
+  if len(issues) != 1 || issues[0].Kind != "incompatible" || issues[0].Found != "17.0.0" {
+    t.Fatalf("unexpected issues: %+v", issues)
+  }
+  if p := issues[0].Path; len(p) != 2 || p[0] != "legacy@1.0.0" { t.Fatalf("unexpected path: %v", p) }
+}
+
+func TestResolvePeersPropagatesAndReportsMissing(t *testing.T) {
+  host := peerTestNode("host", "1.0.0", nil, nil)
+  plugin := peerTestNode("plugin", "1.0.0", nil, map[string]string{"host": "^1.0.0", "other": "^2.0.0"})
+  wrapper := peerTestNode("wrapper", "1.0.0", map[string]string{"plugin": "1.0.0"}, nil)
+  nodes := peerTestGraph(host, plugin, wrapper)
+
+  out, roots, issues, err := resolvePeers(context.Background(), []*GraphNode{host, wrapper}, nodes, nil, nil)
+  if err != nil { t.Fatalf("resolvePeers: %v", err) }
+  // plugin's host comes from above wrapper, so wrapper is an instance too
+  if roots[1].key() != "wrapper@1.0.0(host@1.0.0)" { t.Fatalf("wrapper: %s (%v)", roots[1].key(), keysOf(out)) }
+  if _, ok := out["plugin@1.0.0(host@1.0.0)"]; !ok { t.Fatalf("plugin instance missing: %v", keysOf(out)) }
+  if len(issues) != 1 || issues[0].Kind != "missing" || issues[0].Peer != "other" { t.Fatalf("unexpected issues: %+v", issues) }
+}
+
+func keysOf(m map[string]*GraphNode) []string {
+  var ks []string
+  for k := range m { ks = append(ks, k) }
+  return ks
+}
+
+func TestInstallPeerInstancesAndAutoInstall(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t,
+    testPkg{Name: "host", Version: "1.0.0"},
+    testPkg{Name: "host", Version: "2.0.0"},
+    testPkg{Name: "plugin", Version: "1.0.0", Extra: map[string]any{"peerDependencies": map[string]string{"host": "*"}}},
+    testPkg{Name: "old-tool", Version: "1.0.0", Deps: map[string]string{"host": "1.0.0", "plugin": "1.0.0"}},
+    testPkg{Name: "needs-peer", Version: "1.0.0", Extra: map[string]any{"peerDependencies": map[string]string{"host": "^2.0.0"}}},
+  )
+  store, _ := defaultStoreDir()
+  proj := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(`{"dependencies":{"host":"2.0.0","plugin":"1.0.0","old-tool":"1.0.0"}}`), 0o644)
+  runCLI(t, "install", "--dir", proj)
+  for _, inst := range []string{"1.0.0(host@2.0.0)", "1.0.0(host@1.0.0)"} {
+    target, err := os.Readlink(filepath.Join(store, "plugin", inst, "node_modules", "host"))
+    if err != nil { t.Fatalf("plugin %s has no host link: %v", inst, err) }
+    if want := storePkgPath(store, "host", inst[len("1.0.0(host@"):len(inst)-1]); target != want { t.Fatalf("plugin %s links %s, want %s", inst, target, want) }
+    if _, err := os.Stat(filepath.Join(store, "plugin", inst, "package.json")); err != nil { t.Fatalf("instance not populated: %v", err) }
+  }
+  if err := validateProject(proj); err != nil { t.Fatalf("validate: %v", err) }
+
+  proj2 := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj2, "package.json"), []byte(`{"dependencies":{"needs-peer":"1.0.0"}}`), 0o644)
+  runCLI(t, "install", "--auto-install-peers", "--dir", proj2)
+  lf, err := readLockfile(proj2)
+  if err != nil { t.Fatalf("lockfile: %v", err) }
+  if _, ok := lf.Packages["needs-peer@1.0.0(host@2.0.0)"]; !ok { t.Fatalf("auto-installed peer not used: %v", lf.Packages) }
+  if _, err := os.Lstat(filepath.Join(proj2, "node_modules", "host")); !os.IsNotExist(err) { t.Fatalf("auto-installed peers are not project roots") }
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+// jsonObject is a JSON object that keeps its key order when written back,
+// so editing package.json does not reshuffle the user's file.
+type jsonObject struct {
+  keys   []string
+  values map[string]json.RawMessage
+}
+
+func parseJSONObject(b []byte) (*jsonObject, error) {
+  o := &jsonObject{values: make(map[string]json.RawMessage)}
+  dec := json.NewDecoder(bytes.NewReader(b))
+  tok, err := dec.Token()
+  if err != nil { return nil, err }
+  if d, ok := tok.(json.Delim); !ok || d != '{' { return nil, fmt.Errorf("expected a JSON object") }
+  for dec.More() {
+    tok, err := dec.Token()
+    if err != nil { return nil, err }
+    key, _ := tok.(string)
+    var raw json.RawMessage
+    if err := dec.Decode(&raw); err != nil { return nil, err }
+    if _, dup := o.values[key]; !dup { o.keys = append(o.keys, key) }
+    o.values[key] = raw
+  }
+  return o, nil
+}
+
+func (o *jsonObject) get(key string) (json.RawMessage, bool) {
+  v, ok := o.values[key]
+  return v, ok
+}
+
+// set replaces key in place, or inserts it. New keys keep the object sorted
+// when it already was (npm sorts dependency sections), otherwise they are
+// appended.
+func (o *jsonObject) set(key string, value json.RawMessage) {
+  if _, ok := o.values[key]; ok {
+    o.values[key] = value
+    return
+  }
+  o.values[key] = value
+  if len(o.keys) > 1 && sort.StringsAreSorted(o.keys) {
+    i := sort.SearchStrings(o.keys, key)
+    o.keys = append(o.keys, "")
+    copy(o.keys[i+1:], o.keys[i:])
+    o.keys[i] = key
+    return
+  }
+  o.keys = append(o.keys, key)
+}
+
+func (o *jsonObject) remove(key string) bool {
+  if _, ok := o.values[key]; !ok { return false }
+  delete(o.values, key)
+  for i, k := range o.keys {
+    if k == key {
+      o.keys = append(o.keys[:i], o.keys[i+1:]...)
+      break
+    }
+  }
+  return true
+}
+
+// encode writes the object with the given indent unit, re-indenting nested
+// values to match.
+func (o *jsonObject) encode(indent, prefix string) ([]byte, error) {
+  if len(o.keys) == 0 { return []byte("{}"), nil }
+  var buf bytes.Buffer
+  buf.WriteString("{\n")
+  for i, k := range o.keys {
+    kb, _ := json.Marshal(k)
+    buf.WriteString(prefix + indent)
+    buf.Write(kb)
+    buf.WriteString(": ")
+    if err := json.Indent(&buf, o.values[k], prefix+indent, indent); err != nil { return nil, err }
+    if i < len(o.keys)-1 { buf.WriteString(",") }
+    buf.WriteString("\n")
+  }
+  buf.WriteString(prefix + "}")
+  return buf.Bytes(), nil
+}
+
+// packageJSONFile is an editable package.json that remembers the indentation
+// and line endings it was read with.
+type packageJSONFile struct {
+  path            string
+  obj             *jsonObject
+  indent          string
+  crlf            bool
+  trailingNewline bool
+}
+
+func loadPackageJSONFile(projectDir string) (*packageJSONFile, error) {
+  path := filepath.Join(projectDir, "package.json")
+  b, err := os.ReadFile(path)
+  if os.IsNotExist(err) {
+    return &packageJSONFile{path: path, obj: &jsonObject{values: map[string]json.RawMessage{}}, indent: "  ", trailingNewline: true}, nil
+  }
+  if err != nil { return nil, err }
+  obj, err := parseJSONObject(b)
+  if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+  return &packageJSONFile{
+    path:            path,
+    obj:             obj,
+    indent:          detectIndent(string(b)),
+    crlf:            bytes.Contains(b, []byte("\r\n")),
+    trailingNewline: bytes.HasSuffix(b, []byte("\n")),
+  }, nil
+}
+
+// detectIndent returns the leading whitespace of the first indented line.
+func detectIndent(s string) string {
+  for _, line := range strings.Split(s, "\n") {
+    trimmed := strings.TrimLeft(line, " \t")
+    if trimmed != "" && len(trimmed) < len(line) {
+      return line[:len(line)-len(trimmed)]
+    }
+  }
+  return "  "
+}
+
+func (p *packageJSONFile) section(name string) (*jsonObject, error) {
+  raw, ok := p.obj.get(name)
+  if !ok { return &jsonObject{values: map[string]json.RawMessage{}}, nil }
+  sec, err := parseJSONObject(raw)
+  if err != nil { return nil, fmt.Errorf("package.json %s: %w", name, err) }
+  return sec, nil
+}
+
+func (p *packageJSONFile) putSection(name string, sec *jsonObject) error {
+  b, err := sec.encode(p.indent, "")
+  if err != nil { return err }
+  // dependency sections are appended, never sorted into the top level
+  if _, ok := p.obj.get(name); !ok {
+    p.obj.keys = append(p.obj.keys, name)
+  }
+  p.obj.values[name] = b
+  return nil
+}
+
+// setDependency writes name: spec into section.
+func (p *packageJSONFile) setDependency(section, name, spec string) error {
+  sec, err := p.section(section)
+  if err != nil { return err }
+  v, _ := json.Marshal(spec)
+  sec.set(name, v)
+  return p.putSection(section, sec)
+}
+
+// removeDependency deletes name from section, dropping the section when it
+// ends up empty. It reports whether anything was removed.
+func (p *packageJSONFile) removeDependency(section, name string) (bool, error) {
+  if _, ok := p.obj.get(section); !ok { return false, nil }
+  sec, err := p.section(section)
+  if err != nil { return false, err }
+  if !sec.remove(name) { return false, nil }
+  if len(sec.keys) == 0 {
+    p.obj.remove(section)
+    return true, nil
+  }
+  return true, p.putSection(section, sec)
+}
+
+func (p *packageJSONFile) save() error {
+  b, err := p.obj.encode(p.indent, "")
+  if err != nil { return err }
+  if p.trailingNewline { b = append(b, '\n') }
+  if p.crlf { b = bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n")) }
+  return os.WriteFile(p.path, b, 0o644)
+}
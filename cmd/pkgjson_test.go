@@ -0,0 +1,48 @@
+package cmd
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestPackageJSONEditKeepsFormatting(t *testing.T) {
+  proj := t.TempDir()
+  orig := "{\n    \"name\": \"app\",\n    \"scripts\": {\n        \"test\": \"jest\"\n    },\n    \"dependencies\": {\n        \"a\": \"^1.0.0\",\n        \"c\": \"^1.0.0\"\n    },\n    \"private\": true\n}\n"
+  if err := os.WriteFile(filepath.Join(proj, "package.json"), []byte(orig), 0o644); err != nil { t.Fatal(err) }
+  pj, err := loadPackageJSONFile(proj)
+  if err != nil { t.Fatalf("load: %v", err) }
+  if err := pj.setDependency(sectionProd, "b", "^2.0.0"); err != nil { t.Fatalf("set: %v", err) }
+  if err := pj.save(); err != nil { t.Fatalf("save: %v", err) }
+  got, _ := os.ReadFile(filepath.Join(proj, "package.json"))
+  want := "{\n    \"name\": \"app\",\n    \"scripts\": {\n        \"test\": \"jest\"\n    },\n    \"dependencies\": {\n        \"a\": \"^1.0.0\",\n        \"b\": \"^2.0.0\",\n        \"c\": \"^1.0.0\"\n    },\n    \"private\": true\n}\n"
+  if string(got) != want { t.Fatalf("unexpected package.json:\n%s", got) }
+
+  // removing the last entry drops the section
+  pj, _ = loadPackageJSONFile(proj)
+  for _, n := range []string{"a", "b", "c"} {
+    if ok, err := pj.removeDependency(sectionProd, n); err != nil || !ok { t.Fatalf("remove %s: %v %v", n, ok, err) }
+  }
+  if err := pj.save(); err != nil { t.Fatalf("save: %v", err) }
+  got, _ = os.ReadFile(filepath.Join(proj, "package.json"))
+  want = "{\n    \"name\": \"app\",\n    \"scripts\": {\n        \"test\": \"jest\"\n    },\n    \"private\": true\n}\n"
+  if string(got) != want { t.Fatalf("unexpected package.json:\n%s", got) }
+}
+
+func TestPackageJSONCreatesFileAndKeepsCRLF(t *testing.T) {
+  proj := t.TempDir()
+  pj, err := loadPackageJSONFile(proj)
+  if err != nil { t.Fatalf("load: %v", err) }
+  if err := pj.setDependency(sectionDev, "x", "~1.2.0"); err != nil { t.Fatal(err) }
+  if err := pj.save(); err != nil { t.Fatal(err) }
+  got, _ := os.ReadFile(filepath.Join(proj, "package.json"))
+  if string(got) != "{\n  \"devDependencies\": {\n    \"x\": \"~1.2.0\"\n  }\n}\n" { t.Fatalf("unexpected:\n%s", got) }
+
+  crlf := "{\r\n\t\"name\": \"app\"\r\n}"
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(crlf), 0o644)
+  pj, _ = loadPackageJSONFile(proj)
+  _ = pj.setDependency(sectionProd, "y", "1.0.0")
+  _ = pj.save()
+  got, _ = os.ReadFile(filepath.Join(proj, "package.json"))
+  if string(got) != "{\r\n\t\"name\": \"app\",\r\n\t\"dependencies\": {\r\n\t\t\"y\": \"1.0.0\"\r\n\t}\r\n}" { t.Fatalf("unexpected:\n%q", got) }
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+  "path/filepath"
+  "runtime"
+  "strings"
+
+  "github.com/spf13/cobra"
+)
+
+// platform is an install target in npm's terms (process.platform,
+// process.arch and the libc family on Linux).
+type platform struct {
+  OS   string
+  CPU  string
+  Libc string
+}
+
+// installTarget overrides the host platform field by field (--os/--cpu/--libc).
+var installTarget platform
+
+func nodeOS(goos string) string {
+  switch goos {
+  case "windows":
+    return "win32"
+  case "solaris", "illumos":
+    return "sunos"
+  }
+  return goos
+}
+
+func nodeCPU(goarch string) string {
+  switch goarch {
+  case "amd64":
+    return "x64"
+  case "386":
+    return "ia32"
+  case "ppc64le":
+    return "ppc64"
+  }
+  return goarch
+}
+
+// detectLibc reports musl when its dynamic loader is present, glibc otherwise.
+func detectLibc() string {
+  if runtime.GOOS != "linux" { return "" }
+  if m, _ := filepath.Glob("/lib/ld-musl-*.so.1"); len(m) > 0 { return "musl" }
+  return "glibc"
+}
+
+func hostPlatform() platform {
+  return platform{OS: nodeOS(runtime.GOOS), CPU: nodeCPU(runtime.GOARCH), Libc: detectLibc()}
+}
+
+// currentTarget is the host platform with installTarget applied on top.
+func currentTarget() platform {
+  p := hostPlatform()
+  if installTarget.OS != "" {
+    p.OS = installTarget.OS
+    if p.OS != "linux" { p.Libc = "" }
+  }
+  if installTarget.CPU != "" { p.CPU = installTarget.CPU }
+  if installTarget.Libc != "" { p.Libc = installTarget.Libc }
+  return p
+}
+
+// matchPlatformList applies npm's rules for os/cpu/libc arrays: an empty list
+// or ["any"] matches everything, "!x" excludes x, and otherwise value must be
+// listed. A list made only of exclusions matches anything not excluded.
+func matchPlatformList(list []string, value string) bool {
+  if len(list) == 0 || (len(list) == 1 && list[0] == "any") { return true }
+  match, negated := false, 0
+  for _, entry := range list {
+    if strings.HasPrefix(entry, "!") {
+      if entry[1:] == value { return false }
+      negated++
+      continue
+    }
+    match = match || entry == value
+  }
+  return match || negated == len(list)
+}
+
+// supports reports whether a package can be installed on p. libc is only
+// checked for Linux targets, like npm does.
+func (p platform) supports(md *PackageMetadata) bool {
+  if md == nil { return true }
+  if !matchPlatformList(md.OS, p.OS) || !matchPlatformList(md.CPU, p.CPU) { return false }
+  if p.OS == "linux" && len(md.Libc) > 0 && !matchPlatformList(md.Libc, p.Libc) { return false }
+  return true
+}
+
+func (p platform) String() string {
+  s := p.OS + "/" + p.CPU
+  if p.Libc != "" { s += "/" + p.Libc }
+  return s
+}
+
+func addPlatformFlags(cmd *cobra.Command) {
+  cmd.Flags().String("os", "", "Install for this os instead of the host (e.g. linux, darwin, win32)")
+  cmd.Flags().String("cpu", "", "Install for this cpu instead of the host (e.g. x64, arm64)")
+  cmd.Flags().String("libc", "", "Install for this libc instead of the host (glibc or musl)")
+}
+
+func applyPlatformFlags(cmd *cobra.Command) {
+  installTarget.OS, _ = cmd.Flags().GetString("os")
+  installTarget.CPU, _ = cmd.Flags().GetString("cpu")
+  installTarget.Libc, _ = cmd.Flags().GetString("libc")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+)
+
+func TestMatchPlatformList(t *testing.T) {
+  cases := []struct {
+    list  []string
+    value string
+    want  bool
+  }{
+    {nil, "linux", true},
+    {[]string{"any"}, "win32", true},
+    {[]string{"darwin", "linux"}, "linux", true},
+    {[]string{"darwin"}, "linux", false},
+    {[]string{"!win32"}, "linux", true},
+    {[]string{"!win32"}, "win32", false},
+    {[]string{"linux", "!linux"}, "linux", false},
+  }
+  for _, c := range cases {
+    if got := matchPlatformList(c.list, c.value); got != c.want {
+      t.Errorf("matchPlatformList(%v, %q) = %v, want %v", c.list, c.value, got, c.want)
+    }
+  }
+}
+
+func TestPlatformSupportsLibcOnlyOnLinux(t *testing.T) {
+  md := &PackageMetadata{OS: []string{"linux", "darwin"}, Libc: []string{"musl"}}
+  if (platform{OS: "linux", CPU: "x64", Libc: "glibc"}).supports(md) { t.Fatalf("glibc target should not match a musl package") }
+  if !(platform{OS: "linux", CPU: "x64", Libc: "musl"}).supports(md) { t.Fatalf("musl target should match") }
+  if !(platform{OS: "darwin", CPU: "arm64"}).supports(md) { t.Fatalf("libc is ignored off Linux") }
+}
+
+func TestInstallSkipsOtherPlatforms(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  t.Cleanup(func() { installTarget = platform{} })
+  newTestRegistry(t,
+    testPkg{Name: "esb", Version: "1.0.0", Extra: map[string]any{"optionalDependencies": map[string]string{
+      "@esb/linux-x64": "1.0.0", "@esb/darwin-arm64": "1.0.0",
+    }}},
+    testPkg{Name: "@esb/linux-x64", Version: "1.0.0", Extra: map[string]any{"os": []string{"linux"}, "cpu": []string{"x64"}}},
+    testPkg{Name: "@esb/darwin-arm64", Version: "1.0.0", Extra: map[string]any{"os": []string{"darwin"}, "cpu": []string{"arm64"}}},
+  )
+  proj := t.TempDir()
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(`{"dependencies":{"esb":"1.0.0"}}`), 0o644)
+
+  runCLI(t, "install", "--os", "darwin", "--cpu", "arm64", "--dir", proj)
+  store, _ := defaultStoreDir()
+  if _, err := os.Stat(filepath.Join(store, "@esb", "darwin-arm64", "1.0.0")); err != nil { t.Fatalf("darwin variant should be installed: %v", err) }
+  if _, err := os.Stat(filepath.Join(store, "@esb", "linux-x64", "1.0.0")); !os.IsNotExist(err) { t.Fatalf("linux variant should not be fetched") }
+  if _, err := os.Lstat(filepath.Join(store, "esb", "1.0.0", "node_modules", "@esb", "linux-x64")); !os.IsNotExist(err) { t.Fatalf("linux variant should not be linked") }
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatalf("lockfile: %v", err) }
+  lp, ok := lf.Packages["@esb/linux-x64@1.0.0"]
+  if !ok || len(lp.OS) != 1 || lp.OS[0] != "linux" || lp.Skipped != "" { t.Fatalf("lockfile should list the linux variant unchanged: %+v", lp) }
+}
@@ -0,0 +1,309 @@
+package cmd
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "path"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+)
+
+// Global workspace selection flags (-r, --filter), like pnpm's.
+var (
+  recursive            bool
+  workspaceFilters     []string
+  workspaceConcurrency int
+)
+
+// workspacePackage is a node of the workspace graph used to select and
+// order packages for recursive commands.
+type workspacePackage struct {
+  Name string
+  Dir  string   // slash-separated, "." for the root project
+  Deps []string // dirs of the workspace packages it depends on
+}
+
+func (p *workspacePackage) label() string {
+  if p.Name != "" { return p.Name }
+  return p.Dir
+}
+
+// workspaceGraph lists the root project and its workspaces with the links
+// between them.
+func workspaceGraph(projectDir string, cfg *Config) ([]*workspacePackage, error) {
+  m, err := readProjectManifest(projectDir)
+  if err != nil { return nil, err }
+  importers, err := loadImporters(projectDir, cfg, m)
+  if err != nil { return nil, err }
+  pkgs := make([]*workspacePackage, 0, len(importers))
+  for _, imp := range importers {
+    p := &workspacePackage{Name: imp.Name, Dir: imp.Dir}
+    for _, d := range imp.Deps {
+      if d.Link != "" { p.Deps = append(p.Deps, path.Join(imp.Dir, d.Link)) }
+    }
+    sort.Strings(p.Deps)
+    pkgs = append(pkgs, p)
+  }
+  return pkgs, nil
+}
+
+// selectWorkspaces applies the -r/--filter selection to pkgs. -r alone picks
+// every workspace package but the root. A selector is a name glob, a path
+// glob ("./apps/*" or "{apps/*}"), or "[git-ref]" for packages changed since
+// ref; "..." after it adds dependencies, "..." before it adds dependents,
+// "^" next to "..." leaves the matched packages out, and "!" excludes.
+func selectWorkspaces(projectDir string, pkgs []*workspacePackage, filters []string) ([]*workspacePackage, error) {
+  byDir := make(map[string]*workspacePackage, len(pkgs))
+  for _, p := range pkgs { byDir[p.Dir] = p }
+  selected := map[string]bool{}
+  excluded := map[string]bool{}
+  includes := 0
+  for _, f := range filters {
+    exclude := strings.HasPrefix(f, "!")
+    sel := strings.TrimPrefix(f, "!")
+    withDependents, withDependencies := strings.HasPrefix(sel, "..."), strings.HasSuffix(sel, "...")
+    sel = strings.TrimSuffix(strings.TrimPrefix(sel, "..."), "...")
+    exceptSelf := false
+    if withDependents && strings.HasPrefix(sel, "^") { sel, exceptSelf = sel[1:], true }
+    if withDependencies && strings.HasSuffix(sel, "^") { sel, exceptSelf = sel[:len(sel)-1], true }
+    matched, err := matchSelector(projectDir, pkgs, sel)
+    if err != nil { return nil, err }
+    out := map[string]bool{}
+    for _, d := range matched {
+      if !exceptSelf { out[d] = true }
+      if withDependencies { walkWorkspaces(byDir, d, func(p *workspacePackage) []string { return p.Deps }, out) }
+      if withDependents { walkWorkspaces(byDir, d, func(p *workspacePackage) []string { return dependentsOf(pkgs, p.Dir) }, out) }
+    }
+    target := selected
+    if exclude { target = excluded } else { includes++ }
+    for d := range out { target[d] = true }
+  }
+  if includes == 0 {
+    // a bare -r or only exclusions: everything but the root of a monorepo
+    for _, p := range pkgs {
+      if p.Dir != "." || len(pkgs) == 1 { selected[p.Dir] = true }
+    }
+  }
+  var out []*workspacePackage
+  for _, p := range pkgs {
+    if selected[p.Dir] && !excluded[p.Dir] { out = append(out, p) }
+  }
+  return out, nil
+}
+
+func matchSelector(projectDir string, pkgs []*workspacePackage, sel string) ([]string, error) {
+  var out []string
+  switch {
+  case strings.HasPrefix(sel, "[") && strings.HasSuffix(sel, "]"):
+    changed, err := changedFiles(projectDir, sel[1:len(sel)-1])
+    if err != nil { return nil, err }
+    for _, p := range pkgs {
+      for _, f := range changed {
+        if ownerDir(pkgs, f) == p.Dir { out = append(out, p.Dir); break }
+      }
+    }
+  case strings.HasPrefix(sel, "{") && strings.HasSuffix(sel, "}"), strings.HasPrefix(sel, "."), strings.HasPrefix(sel, "/"):
+    pattern := strings.TrimSuffix(strings.TrimPrefix(sel, "{"), "}")
+    if filepath.IsAbs(pattern) {
+      if abs, err := filepath.Abs(projectDir); err == nil {
+        if rel, err := filepath.Rel(abs, pattern); err == nil { pattern = rel }
+      }
+    }
+    pattern = path.Clean(filepath.ToSlash(pattern))
+    for _, p := range pkgs {
+      if ok, err := path.Match(pattern, p.Dir); err != nil {
+        return nil, fmt.Errorf("filter %q: %w", sel, err)
+      } else if ok { out = append(out, p.Dir) }
+    }
+  default:
+    for _, p := range pkgs {
+      if ok, err := path.Match(sel, p.Name); err != nil {
+        return nil, fmt.Errorf("filter %q: %w", sel, err)
+      } else if ok && p.Name != "" { out = append(out, p.Dir) }
+    }
+  }
+  if len(out) == 0 { fmt.Printf("Warning: no workspace package matches %q\n", sel) }
+  return out, nil
+}
+
+// changedFiles lists files changed since ref, uncommitted and untracked
+// ones included, relative to projectDir.
+func changedFiles(projectDir, ref string) ([]string, error) {
+  var files []string
+  for _, args := range [][]string{{"diff", "--name-only", "--relative", ref}, {"ls-files", "--others", "--exclude-standard"}} {
+    out, err := exec.Command("git", append([]string{"-C", projectDir}, args...)...).Output()
+    if err != nil { return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err) }
+    for _, l := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+      if l != "" { files = append(files, l) }
+    }
+  }
+  return files, nil
+}
+
+// ownerDir returns the innermost workspace package directory containing file.
+func ownerDir(pkgs []*workspacePackage, file string) string {
+  best := "."
+  for _, p := range pkgs {
+    if p.Dir != "." && strings.HasPrefix(file, p.Dir+"/") && len(p.Dir) > len(best) { best = p.Dir }
+  }
+  return best
+}
+
+func dependentsOf(pkgs []*workspacePackage, dir string) []string {
+  var out []string
+  for _, p := range pkgs {
+    for _, d := range p.Deps {
+      if d == dir { out = append(out, p.Dir) }
+    }
+  }
+  return out
+}
+
+func walkWorkspaces(byDir map[string]*workspacePackage, start string, next func(*workspacePackage) []string, out map[string]bool) {
+  seen := map[string]bool{start: true}
+  queue := []string{start}
+  for len(queue) > 0 {
+    p, ok := byDir[queue[0]]
+    queue = queue[1:]
+    if !ok { continue }
+    for _, d := range next(p) {
+      if seen[d] { continue }
+      seen[d] = true
+      out[d] = true
+      queue = append(queue, d)
+    }
+  }
+}
+
+// runTopological calls fn for pkgs with at most concurrency calls at once,
+// starting a package only after the selected packages it depends on are
+// done. After a failure no new package is started; the first error is
+// returned. Cycles are broken by starting the remaining packages in order.
+func runTopological(pkgs []*workspacePackage, concurrency int, fn func(*workspacePackage) error) error {
+  if concurrency <= 0 { concurrency = 1 }
+  pending := make(map[string]int, len(pkgs))
+  for _, p := range pkgs { pending[p.Dir] = 0 }
+  for _, p := range pkgs {
+    for _, d := range p.Deps {
+      if _, ok := pending[d]; ok && d != p.Dir { pending[p.Dir]++ }
+    }
+  }
+  type result struct {
+    pkg *workspacePackage
+    err error
+  }
+  results := make(chan result)
+  started := map[string]bool{}
+  running := 0
+  var firstErr error
+  for len(started) < len(pkgs) || running > 0 {
+    if firstErr == nil {
+      var ready []*workspacePackage
+      for _, p := range pkgs {
+        if !started[p.Dir] && pending[p.Dir] <= 0 { ready = append(ready, p) }
+      }
+      if len(ready) == 0 && running == 0 {
+        // a cycle: take the next package regardless of its deps
+        for _, p := range pkgs {
+          if !started[p.Dir] { ready = append(ready, p); break }
+        }
+      }
+      for _, p := range ready {
+        if running >= concurrency { break }
+        started[p.Dir] = true
+        running++
+        go func(p *workspacePackage) { results <- result{p, fn(p)} }(p)
+      }
+    } else if running == 0 {
+      break
+    }
+    r := <-results
+    running--
+    if r.err != nil && firstErr == nil { firstErr = r.err }
+    for _, p := range dependentsOf(pkgs, r.pkg.Dir) { pending[p]-- }
+  }
+  return firstErr
+}
+
+// prefixWriter writes whole lines to w with a prefix; writers sharing mu
+// keep lines of parallel scripts from interleaving.
+type prefixWriter struct {
+  mu     *sync.Mutex
+  w      io.Writer
+  prefix string
+  buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  p.buf = append(p.buf, b...)
+  for {
+    i := bytes.IndexByte(p.buf, '\n')
+    if i < 0 { break }
+    if _, err := fmt.Fprintf(p.w, "%s%s", p.prefix, p.buf[:i+1]); err != nil { return 0, err }
+    p.buf = p.buf[i+1:]
+  }
+  return len(b), nil
+}
+
+// flush writes a trailing partial line.
+func (p *prefixWriter) flush() {
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  if len(p.buf) > 0 { fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf) }
+  p.buf = nil
+}
+
+// recursiveSelected reports whether -r or --filter was given.
+func recursiveSelected() bool { return recursive || len(workspaceFilters) > 0 }
+
+// selectedWorkspaces loads the workspace graph of projectDir and applies
+// the global -r/--filter flags.
+func selectedWorkspaces(projectDir string) ([]*workspacePackage, error) {
+  cfg, _ := loadConfig(projectDir)
+  pkgs, err := workspaceGraph(projectDir, cfg)
+  if err != nil { return nil, err }
+  return selectWorkspaces(projectDir, pkgs, workspaceFilters)
+}
+
+// runInWorkspaces runs fn in every selected package that has script (any
+// package when script is empty), in topological order and with prefixed
+// output.
+func runInWorkspaces(projectDir, script string, fn func(dir string, sio scriptIO) error) error {
+  pkgs, err := selectedWorkspaces(projectDir)
+  if err != nil { return err }
+  var targets []*workspacePackage
+  for _, p := range pkgs {
+    if script != "" {
+      pj, err := readPackageJSON(filepath.Join(projectDir, filepath.FromSlash(p.Dir)))
+      if err != nil || pj.Scripts[script] == "" { continue }
+    }
+    targets = append(targets, p)
+  }
+  if len(targets) == 0 {
+    if script != "" { return fmt.Errorf("none of the selected packages has a %q script", script) }
+    return fmt.Errorf("no workspace package selected")
+  }
+  var mu sync.Mutex
+  return runTopological(targets, workspaceConcurrency, func(p *workspacePackage) error {
+    stdout := &prefixWriter{mu: &mu, w: os.Stdout, prefix: p.label() + ": "}
+    stderr := &prefixWriter{mu: &mu, w: os.Stderr, prefix: p.label() + ": "}
+    err := fn(filepath.Join(projectDir, filepath.FromSlash(p.Dir)), scriptIO{Stdout: stdout, Stderr: stderr})
+    stdout.flush()
+    stderr.flush()
+    if err != nil { return fmt.Errorf("%s: %w", p.label(), err) }
+    return nil
+  })
+}
+
+func init() {
+  rootCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "r", false, "Run the command in every workspace package")
+  rootCmd.PersistentFlags().StringArrayVar(&workspaceFilters, "filter", nil, "Select workspace packages: name, ./path glob, [git-ref], with ... for dependencies/dependents (repeatable)")
+  rootCmd.PersistentFlags().IntVar(&workspaceConcurrency, "workspace-concurrency", 4, "Maximum number of workspace packages processed in parallel")
+}
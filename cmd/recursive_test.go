@@ -0,0 +1,133 @@
+package cmd
+
+import (
+  "os"
+  "os/exec"
+  "path/filepath"
+  "reflect"
+  "runtime"
+  "strings"
+  "sync"
+  "testing"
+)
+
+func testWorkspaceGraph() []*workspacePackage {
+  return []*workspacePackage{
+    {Name: "mono", Dir: "."},
+    {Name: "web", Dir: "apps/web", Deps: []string{"packages/ui"}},
+    {Name: "@acme/ui", Dir: "packages/ui", Deps: []string{"packages/utils"}},
+    {Name: "@acme/utils", Dir: "packages/utils"},
+    {Name: "docs", Dir: "apps/docs"},
+  }
+}
+
+func selectedDirs(t *testing.T, filters ...string) []string {
+  t.Helper()
+  pkgs, err := selectWorkspaces(t.TempDir(), testWorkspaceGraph(), filters)
+  if err != nil { t.Fatal(err) }
+  var dirs []string
+  for _, p := range pkgs { dirs = append(dirs, p.Dir) }
+  return dirs
+}
+
+func TestSelectWorkspaces(t *testing.T) {
+  cases := []struct {
+    filters []string
+    want    []string
+  }{
+    {nil, []string{"apps/web", "packages/ui", "packages/utils", "apps/docs"}},
+    {[]string{"@acme/*"}, []string{"packages/ui", "packages/utils"}},
+    {[]string{"./apps/*"}, []string{"apps/web", "apps/docs"}},
+    {[]string{"{apps/web}..."}, []string{"apps/web", "packages/ui", "packages/utils"}},
+    {[]string{"web^..."}, []string{"packages/ui", "packages/utils"}},
+    {[]string{"...@acme/utils"}, []string{"apps/web", "packages/ui", "packages/utils"}},
+    {[]string{"...^@acme/utils"}, []string{"apps/web", "packages/ui"}},
+    {[]string{"!docs"}, []string{"apps/web", "packages/ui", "packages/utils"}},
+    {[]string{"web...", "!@acme/ui"}, []string{"apps/web", "packages/utils"}},
+    {[]string{"."}, []string{"."}},
+  }
+  for _, c := range cases {
+    if got := selectedDirs(t, c.filters...); !reflect.DeepEqual(got, c.want) {
+      t.Errorf("filters %q: got %v, want %v", c.filters, got, c.want)
+    }
+  }
+}
+
+func TestSelectWorkspacesChangedSinceRef(t *testing.T) {
+  root := t.TempDir()
+  git := func(args ...string) {
+    c := exec.Command("git", append([]string{"-C", root, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+    if out, err := c.CombinedOutput(); err != nil { t.Skipf("git %v: %v\n%s", args, err, out) }
+  }
+  writeTestFile(t, filepath.Join(root, "packages", "ui", "index.js"), "")
+  writeTestFile(t, filepath.Join(root, "packages", "utils", "index.js"), "")
+  git("init", "-q")
+  git("add", "-A")
+  git("commit", "-qm", "init")
+  writeTestFile(t, filepath.Join(root, "packages", "utils", "index.js"), "changed")
+
+  pkgs, err := selectWorkspaces(root, testWorkspaceGraph(), []string{"...[HEAD]"})
+  if err != nil { t.Fatal(err) }
+  var dirs []string
+  for _, p := range pkgs { dirs = append(dirs, p.Dir) }
+  if want := []string{"apps/web", "packages/ui", "packages/utils"}; !reflect.DeepEqual(dirs, want) { t.Fatalf("got %v, want %v", dirs, want) }
+}
+
+func TestRunTopological(t *testing.T) {
+  var mu sync.Mutex
+  var order []string
+  pkgs, _ := selectWorkspaces(t.TempDir(), testWorkspaceGraph(), nil)
+  err := runTopological(pkgs, 2, func(p *workspacePackage) error {
+    mu.Lock()
+    defer mu.Unlock()
+    order = append(order, p.Dir)
+    return nil
+  })
+  if err != nil { t.Fatal(err) }
+  pos := map[string]int{}
+  for i, d := range order { pos[d] = i }
+  if len(order) != 4 || pos["packages/utils"] > pos["packages/ui"] || pos["packages/ui"] > pos["apps/web"] { t.Fatalf("not topological: %v", order) }
+}
+
+func TestRecursiveRunInWorkspaces(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("scripts use sh") }
+  root := t.TempDir()
+  log := filepath.Join(root, "log")
+  writeTestFile(t, filepath.Join(root, "package.json"), `{"name":"mono","workspaces":["packages/*"],"scripts":{"build":"echo root >> log"}}`)
+  writeTestFile(t, filepath.Join(root, "packages", "app", "package.json"), `{"name":"app","dependencies":{"lib":"workspace:*"},"scripts":{"build":"echo app >> ../../log"}}`)
+  writeTestFile(t, filepath.Join(root, "packages", "lib", "package.json"), `{"name":"lib","version":"1.0.0","scripts":{"build":"echo lib >> ../../log"}}`)
+  writeTestFile(t, filepath.Join(root, "packages", "other", "package.json"), `{"name":"other"}`)
+
+  runCLI(t, "-r", "run", "--dir", root, "build")
+  if b, _ := os.ReadFile(log); string(b) != "lib\napp\n" { t.Fatalf("unexpected order %q", b) }
+
+  _ = os.Remove(log)
+  runCLI(t, "--filter", "app", "run", "--dir", root, "build")
+  if b, _ := os.ReadFile(log); strings.TrimSpace(string(b)) != "app" { t.Fatalf("filter should run app only, got %q", b) }
+}
+
+func TestPrefixWriter(t *testing.T) {
+  var buf strings.Builder
+  var mu sync.Mutex
+  w := &prefixWriter{mu: &mu, w: &buf, prefix: "app: "}
+  _, _ = w.Write([]byte("one\ntw"))
+  _, _ = w.Write([]byte("o\nthree"))
+  w.flush()
+  if want := "app: one\napp: two\napp: three\n"; buf.String() != want { t.Fatalf("got %q", buf.String()) }
+}
+
+func TestFilteredInstallLinksSelectedWorkspaces(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t, testPkg{Name: "x", Version: "1.0.0"})
+  root := t.TempDir()
+  writeTestFile(t, filepath.Join(root, "package.json"), `{"name":"mono","workspaces":["packages/*"]}`)
+  writeTestFile(t, filepath.Join(root, "packages", "app", "package.json"), `{"name":"app","dependencies":{"x":"1.0.0"}}`)
+  writeTestFile(t, filepath.Join(root, "packages", "other", "package.json"), `{"name":"other","dependencies":{"x":"1.0.0"}}`)
+
+  runCLI(t, "--filter", "./packages/app", "install", "--dir", root)
+  if _, err := os.Lstat(filepath.Join(root, "packages", "app", "node_modules", "x")); err != nil { t.Fatalf("app not installed: %v", err) }
+  if _, err := os.Lstat(filepath.Join(root, "packages", "other", "node_modules")); !os.IsNotExist(err) { t.Fatalf("other should not be installed") }
+  lf, err := readLockfile(root)
+  if err != nil { t.Fatal(err) }
+  if _, ok := lf.Importers["packages/other"]; !ok { t.Fatalf("lockfile should keep every workspace: %+v", lf.Importers) }
+}
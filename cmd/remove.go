@@ -23,9 +23,36 @@ func removeFromProject(projectDir string, pkgs []string) error {
   return nil
 }
 
+// unlinkRoots removes the project links of roots left out of an install,
+// e.g. devDependencies from an earlier full install when running --prod.
+func unlinkRoots(projectDir string, roots []*GraphNode) error {
+  if len(roots) == 0 { return nil }
+  names := make([]string, 0, len(roots))
+  for _, r := range roots { names = append(names, r.Name) }
+  return removeFromProject(projectDir, names)
+}
+
+// removeFromManifest deletes pkgs from every dependency section of the
+// project's package.json, if there is one.
+func removeFromManifest(projectDir string, pkgs []string) error {
+  if _, err := os.Stat(filepath.Join(projectDir, "package.json")); os.IsNotExist(err) { return nil }
+  pj, err := loadPackageJSONFile(projectDir)
+  if err != nil { return err }
+  changed := false
+  for _, p := range pkgs {
+    for _, section := range []string{sectionProd, sectionDev, sectionOptional, sectionPeer} {
+      removed, err := pj.removeDependency(section, p)
+      if err != nil { return err }
+      changed = changed || removed
+    }
+  }
+  if !changed { return nil }
+  return pj.save()
+}
+
 var removeCmd = &cobra.Command{
   Use:   "remove <package> [...]",
-  Short: "Remove packages from project node_modules (keeps store)",
+  Short: "Remove packages from package.json and project node_modules (keeps store)",
   Args:  cobra.MinimumNArgs(1),
   Run: func(cmd *cobra.Command, args []string) {
     projectDir, _ := cmd.Flags().GetString("dir")
@@ -34,6 +61,9 @@ var removeCmd = &cobra.Command{
       fmt.Println("Error:", err)
       os.Exit(1)
     }
+    if err := removeFromManifest(projectDir, args); err != nil {
+      fmt.Println("Warning: failed to update package.json:", err)
+    }
     // Update lockfile to drop roots and prune unreachable
     if err := removeFromLockfile(projectDir, args); err != nil {
       fmt.Println("Warning: failed to update lockfile:", err)
@@ -53,3 +53,12 @@ func TestRemoveUpdatesLockfile(t *testing.T) {
   if len(lf2.Roots) != 1 || lf2.Roots[0] != "b@1.0.0" { t.Fatalf("unexpected roots: %+v", lf2.Roots) }
   if _, ok := lf2.Packages["a@1.0.0"]; ok { t.Fatalf("package a still present") }
 }
+
+func TestRemoveFromManifest(t *testing.T) {
+  proj := t.TempDir()
+  pj := "{\n  \"dependencies\": {\n    \"a\": \"^1.0.0\",\n    \"b\": \"^1.0.0\"\n  },\n  \"peerDependencies\": {\n    \"a\": \"*\"\n  }\n}\n"
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(pj), 0o644)
+  if err := removeFromManifest(proj, []string{"a"}); err != nil { t.Fatalf("removeFromManifest: %v", err) }
+  got, _ := os.ReadFile(filepath.Join(proj, "package.json"))
+  if string(got) != "{\n  \"dependencies\": {\n    \"b\": \"^1.0.0\"\n  }\n}\n" { t.Fatalf("unexpected package.json:\n%s", got) }
+}
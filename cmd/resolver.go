@@ -0,0 +1,199 @@
+package cmd
+
+import (
+  "context"
+  "sort"
+  "strings"
+)
+
+// maxBacktracks bounds how often resolveRoots re-resolves with new pins.
+const maxBacktracks = 8
+
+// resolver carries the state shared by the graph resolutions of one install:
+// the version pool, the versions pinned while backtracking out of peer
+// conflicts, every request made for a package along with the chain of
+// dependents behind it, and the deprecated versions selected.
+type resolver struct {
+  pool       versionPool
+  pins       map[string]string    // package -> version to use where it fits
+  requests   map[string][]request // package -> specs it was requested with
+  overrides  []*overrideRule      // most specific first
+  deprecated []deprecatedUse      // printed once resolveRoots settles
+}
+
+// request is one spec for a package; path lists its dependents from the
+// root, ending with the requirement itself (name@spec).
+type request struct {
+  spec string
+  path []string
+}
+
+func newResolver(pool versionPool) *resolver {
+  if pool == nil { pool = versionPool{} }
+  return &resolver{pool: pool, pins: map[string]string{}, requests: map[string][]request{}}
+}
+
+// resolve is resolveDep that first tries, for a registry range, the version
+// pinned by backtracking and then the pooled versions (tags and source specs
+// always resolve), as long as they were released before releaseCutoff. It
+// records the request and adds the result to the pool.
+func (r *resolver) resolve(ctx context.Context, name, spec string, path []string, cache map[string]*RootDoc) (string, *PackageMetadata, error) {
+  pkg, rng := name, spec
+  real, rr, isAlias := parseAlias(spec)
+  if isAlias { pkg, rng = real, rr }
+  r.requests[pkg] = append(r.requests[pkg], request{spec: rng, path: path})
+  if rng != "" && !isSourceSpec(rng) && validRange(rng) {
+    v, ok := r.pins[pkg], false
+    if v != "" { ok = versionSatisfies(v, rng) }
+    if !ok { v, ok = r.pool.pick(pkg, rng) }
+    if ok && releasedVersion(ctx, pkg, v, cache) {
+      if md, err := metadataForExactVersion(ctx, pkg, v, cache); err == nil {
+        if isAlias { v = aliasPrefix + keyOf(pkg, v) }
+        return v, md, nil
+      }
+    }
+  }
+  v, md, err := resolveDep(ctx, name, spec, cache)
+  if err != nil { return "", nil, err }
+  r.pool.add(name, v)
+  return v, md, nil
+}
+
+// resolveEdge resolves dependency name@spec of the node reached by chain,
+// with local specs relative to dir, unless an override replaces the spec. It
+// also returns the request's path.
+func (r *resolver) resolveEdge(ctx context.Context, chain []string, name, spec, dir string, cache map[string]*RootDoc) (string, *PackageMetadata, []string, error) {
+  o := r.override(chain, name)
+  if o != nil && o.target().rng == nil { spec, dir = o.spec, "" }
+  path := appendPath(chain, keyOf(name, spec))
+  v, md, err := r.resolve(ctx, name, absLocalSpec(spec, dir), path, cache)
+  if err != nil || o == nil || o.target().rng == nil { return v, md, path, err }
+  // a ranged override applies to the versions it names
+  picked := v
+  if isSourceSpec(v) { picked = md.Version }
+  if !rangeHas(o.target().rng, picked) { return v, md, path, nil }
+  path = appendPath(chain, keyOf(name, o.spec))
+  v, md, err = r.resolve(ctx, name, o.spec, path, cache)
+  return v, md, path, err
+}
+
+// snapshot returns a function restoring the pool, requests and deprecated
+// versions to their current state, for re-running a resolution with new pins.
+func (r *resolver) snapshot() func() {
+  pool, requests, deprecated := r.pool.clone(), cloneRequests(r.requests), len(r.deprecated)
+  return func() { r.pool, r.requests, r.deprecated = pool.clone(), cloneRequests(requests), r.deprecated[:deprecated] }
+}
+
+func cloneRequests(m map[string][]request) map[string][]request {
+  out := make(map[string][]request, len(m))
+  for name, rs := range m { out[name] = append([]request{}, rs...) }
+  return out
+}
+
+// backtrack pins versions that remove incompatible peer issues: a version of
+// the peer that satisfies its peer range and every request for it, or else a
+// version of the dependent whose peer range accepts the peer found. nodes is
+// the graph before peer resolution. It reports whether a pin changed.
+func (r *resolver) backtrack(ctx context.Context, issues []PeerIssue, nodes map[string]*GraphNode, cache map[string]*RootDoc) bool {
+  changed := false
+  for _, i := range issues {
+    if i.Kind != "incompatible" { continue }
+    dep, peer := nodes[i.Package], nodes[keyOf(i.Peer, i.Found)]
+    if dep == nil || peer == nil || isSourceSpec(dep.Version) || isSourceSpec(peer.Version) { continue }
+    v := r.candidate(ctx, peer.pkgName(), cache, func(md *PackageMetadata) bool { return versionSatisfies(md.Version, i.Wanted) })
+    if v != "" && r.pin(peer.pkgName(), v) { changed = true; continue }
+    found := peer.pkgVersion()
+    v = r.candidate(ctx, dep.pkgName(), cache, func(md *PackageMetadata) bool {
+      want, ok := md.PeerDependencies[i.Peer]
+      return !ok || versionSatisfies(found, want)
+    })
+    if v != "" && r.pin(dep.pkgName(), v) { changed = true }
+  }
+  return changed
+}
+
+// candidate returns the highest registry version of pkg that satisfies every
+// range pkg was requested with and ok, or "" if there is none.
+func (r *resolver) candidate(ctx context.Context, pkg string, cache map[string]*RootDoc, ok func(*PackageMetadata) bool) string {
+  var ranges []string
+  for _, req := range r.requests[pkg] {
+    if req.spec != "" && validRange(req.spec) { ranges = append(ranges, req.spec) }
+  }
+  if len(ranges) == 0 { return "" }
+  rd, err := fetchRootDoc(ctx, pkg, cache)
+  if err != nil { return "" }
+  type cand struct {
+    s string
+    v *npmVersion
+  }
+  var cands []cand
+  for s := range rd.Versions {
+    if v, err := parseVersion(s); err == nil { cands = append(cands, cand{s, v}) }
+  }
+  sort.Slice(cands, func(i, j int) bool { return cands[i].v.compare(cands[j].v) > 0 })
+  for _, c := range cands {
+    fits := true
+    for _, rng := range ranges {
+      if !versionSatisfies(c.s, rng) { fits = false; break }
+    }
+    if md := rd.Versions[c.s]; fits && rd.released(c.s) && ok(&md) { return c.s }
+  }
+  return ""
+}
+
+func (r *resolver) pin(pkg, version string) bool {
+  if r.pins[pkg] == version { return false }
+  logf("Backtracking: trying %s@%s\n", pkg, version)
+  r.pins[pkg] = version
+  return true
+}
+
+// conflict is a requirement that could not be resolved, with every chain
+// from a root to it (ending with name@spec).
+type conflict struct {
+  err   error
+  paths [][]string
+}
+
+// resolveError reports the requirements a resolution failed on.
+type resolveError struct {
+  conflicts []*conflict
+}
+
+func (e *resolveError) Error() string {
+  var b strings.Builder
+  for i, c := range e.conflicts {
+    if i > 0 { b.WriteString("\n") }
+    b.WriteString(c.err.Error())
+    for _, p := range c.paths { b.WriteString("\n  " + strings.Join(p, " > ")) }
+  }
+  return b.String()
+}
+
+// add records a failed request, merging it with an earlier failure of the
+// same requirement.
+func (e *resolveError) add(err error, path []string) {
+  for _, c := range e.conflicts {
+    if c.err.Error() == err.Error() {
+      c.paths = append(c.paths, path)
+      return
+    }
+  }
+  e.conflicts = append(e.conflicts, &conflict{err: err, paths: [][]string{path}})
+}
+
+// merge adds the conflicts of err when it is a resolveError and reports
+// whether it was one.
+func (e *resolveError) merge(err error) bool {
+  re, ok := err.(*resolveError)
+  if !ok { return false }
+  for _, c := range re.conflicts {
+    for _, p := range c.paths { e.add(c.err, p) }
+  }
+  return true
+}
+
+// appendPath extends a dependent chain without sharing its backing array.
+func appendPath(path []string, elem string) []string {
+  return append(append(make([]string, 0, len(path)+1), path...), elem)
+}
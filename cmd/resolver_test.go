@@ -0,0 +1,49 @@
+package cmd
+
+import (
+  "context"
+  "strings"
+  "testing"
+)
+
+func TestResolveConflictPaths(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "lodash", Version: "4.17.21"},
+    testPkg{Name: "b", Version: "1.0.0", Deps: map[string]string{"lodash": "^9"}},
+    testPkg{Name: "a", Version: "1.0.0", Deps: map[string]string{"b": "^1"}},
+    testPkg{Name: "c", Version: "1.0.0", Deps: map[string]string{"lodash": "^9", "b": "1.0.0"}},
+  )
+  deps := []rootDep{{Name: "a", Spec: "1.0.0", Section: sectionProd}, {Name: "c", Spec: "1.0.0", Section: sectionProd}}
+  _, _, _, err := resolveRoots(context.Background(), deps, map[string]*RootDoc{}, nil)
+  if err == nil { t.Fatal("expected a resolution error") }
+  msg := err.Error()
+  for _, want := range []string{`no versions of lodash satisfy "^9"`, "a@1.0.0 > b@1.0.0 > lodash@^9", "c@1.0.0 > lodash@^9", "c@1.0.0 > b@1.0.0 > lodash@^9"} {
+    if !strings.Contains(msg, want) { t.Errorf("error lacks %q:\n%s", want, msg) }
+  }
+  if strings.Count(msg, "no versions of lodash") != 1 { t.Errorf("the same requirement should be reported once:\n%s", msg) }
+}
+
+func TestResolveBacktracksPeerConflicts(t *testing.T) {
+  peer := func(rng string) map[string]any { return map[string]any{"peerDependencies": map[string]string{"react": rng}} }
+  newTestRegistry(t,
+    testPkg{Name: "react", Version: "17.0.0"},
+    testPkg{Name: "react", Version: "18.0.0"},
+    testPkg{Name: "old-plugin", Version: "1.0.0", Extra: peer("^17")},
+    testPkg{Name: "plugin", Version: "1.0.0", Extra: peer("^18")},
+    testPkg{Name: "plugin", Version: "2.0.0", Extra: peer("^19")},
+  )
+  versions := func(deps ...rootDep) map[string]string {
+    t.Helper()
+    _, roots, _, err := resolveRoots(context.Background(), deps, map[string]*RootDoc{}, nil)
+    if err != nil { t.Fatal(err) }
+    out := map[string]string{}
+    for _, r := range roots { out[r.Name] = r.Version }
+    return out
+  }
+  // the peer is moved to a version every dependent accepts
+  got := versions(rootDep{Name: "react", Spec: ">=17", Section: sectionProd}, rootDep{Name: "old-plugin", Spec: "1.0.0", Section: sectionProd})
+  if got["react"] != "17.0.0" { t.Errorf("react = %s, want 17.0.0", got["react"]) }
+  // or else the dependent falls back to a version accepting the peer
+  got = versions(rootDep{Name: "react", Spec: "^18", Section: sectionProd}, rootDep{Name: "plugin", Spec: "*", Section: sectionProd})
+  if got["react"] != "18.0.0" || got["plugin"] != "1.0.0" { t.Errorf("got %v, want react 18.0.0 and plugin 1.0.0", got) }
+}
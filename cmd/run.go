@@ -0,0 +1,121 @@
+package cmd
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "sort"
+
+  "github.com/spf13/cobra"
+)
+
+// projectInstallEvents run for the project itself around `wlim install`,
+// like npm: preinstall before linking, the rest after dependencies are built.
+var projectInstallEvents = []string{"install", "postinstall", "preprepare", "prepare", "postprepare"}
+
+// binPathDirs returns node_modules/.bin of dir and of every ancestor, nearest
+// first, as npm puts them on PATH for scripts.
+func binPathDirs(dir string) []string {
+  abs, err := filepath.Abs(dir)
+  if err != nil { abs = dir }
+  var out []string
+  for {
+    out = append(out, filepath.Join(abs, "node_modules", ".bin"))
+    parent := filepath.Dir(abs)
+    if parent == abs { return out }
+    abs = parent
+  }
+}
+
+// runProjectScript runs the script name from projectDir/package.json with
+// its pre/post hooks; args go to the main script only.
+func runProjectScript(ctx context.Context, projectDir, name string, args []string, sio scriptIO) error {
+  pj, err := readPackageJSON(projectDir)
+  if err != nil { return err }
+  script, ok := pj.Scripts[name]
+  if !ok { return fmt.Errorf("missing script: %s", name) }
+  pathDirs := binPathDirs(projectDir)
+  if pre := pj.Scripts["pre"+name]; pre != "" {
+    if err := runScript(ctx, projectDir, pj, "pre"+name, pre, pathDirs, nil, sio); err != nil { return err }
+  }
+  if err := runScript(ctx, projectDir, pj, name, script, pathDirs, args, sio); err != nil { return err }
+  if post := pj.Scripts["post"+name]; post != "" {
+    if err := runScript(ctx, projectDir, pj, "post"+name, post, pathDirs, nil, sio); err != nil { return err }
+  }
+  return nil
+}
+
+// runProjectLifecycle runs the given events of the project that are defined,
+// skipping the others. A missing package.json is not an error.
+func runProjectLifecycle(ctx context.Context, projectDir string, events []string) error {
+  pj, err := readPackageJSON(projectDir)
+  if os.IsNotExist(err) { return nil }
+  if err != nil { return err }
+  for _, ev := range events {
+    script := pj.Scripts[ev]
+    if script == "" { continue }
+    fmt.Printf("> %s\n> %s\n", ev, script)
+    if err := runScript(ctx, projectDir, pj, ev, script, binPathDirs(projectDir), nil, stdIO()); err != nil {
+      return fmt.Errorf("%s script: %w", ev, err)
+    }
+  }
+  return nil
+}
+
+// passthroughArgs returns the arguments after the script or binary name in
+// args. Flag parsing stops at the name, so a "--" separating them from wlim's
+// flags is still there and is dropped.
+func passthroughArgs(args []string) []string {
+  rest := args[1:]
+  if len(rest) > 0 && rest[0] == "--" { rest = rest[1:] }
+  return rest
+}
+
+// exitCode returns the exit status of a failed script, or 1.
+func exitCode(err error) int {
+  var ee *exec.ExitError
+  if errors.As(err, &ee) && ee.ExitCode() > 0 { return ee.ExitCode() }
+  return 1
+}
+
+var runCmd = &cobra.Command{
+  Use:   "run [<script> [-- <args>...]]",
+  Short: "Run a script from package.json",
+  Long:  "Run a script from the project's package.json with node_modules/.bin on PATH, including its pre/post hooks. Without a script name, the available scripts are listed.",
+  Args:  cobra.ArbitraryArgs,
+  Run: func(cmd *cobra.Command, args []string) {
+    projectDir, _ := cmd.Flags().GetString("dir")
+    if projectDir == "" { projectDir = "." }
+    if len(args) > 0 && recursiveSelected() {
+      err := runInWorkspaces(projectDir, args[0], func(dir string, sio scriptIO) error {
+        return runProjectScript(context.Background(), dir, args[0], passthroughArgs(args), sio)
+      })
+      if err != nil { fmt.Println("Error:", err) }
+      exitOnError(err)
+      return
+    }
+    if len(args) == 0 {
+      pj, err := readPackageJSON(projectDir)
+      if err != nil {
+        fmt.Println("Error:", err)
+        os.Exit(1)
+      }
+      names := make([]string, 0, len(pj.Scripts))
+      for n := range pj.Scripts { names = append(names, n) }
+      sort.Strings(names)
+      for _, n := range names { fmt.Printf("  %s\n    %s\n", n, pj.Scripts[n]) }
+      return
+    }
+    exitOnError(runProjectScript(context.Background(), projectDir, args[0], passthroughArgs(args), stdIO()))
+  },
+}
+
+func init() {
+  runCmd.Flags().String("dir", ".", "Project directory containing package.json")
+  // everything after the script name belongs to the script
+  runCmd.Flags().SetInterspersed(false)
+  rootCmd.AddCommand(runCmd)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+)
+
+func TestRunProjectScript(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("scripts use sh") }
+  parent := t.TempDir()
+  proj := filepath.Join(parent, "app")
+  // a bin from an ancestor node_modules is on PATH
+  bin := filepath.Join(parent, "node_modules", ".bin")
+  if err := os.MkdirAll(bin, 0o755); err != nil { t.Fatal(err) }
+  _ = os.WriteFile(filepath.Join(bin, "greet"), []byte("#!/bin/sh\necho \"hello $*\" >> out\n"), 0o755)
+  _ = os.MkdirAll(proj, 0o755)
+  pj := `{"name":"app","version":"1.2.3","scripts":{
+    "prebuild":"echo pre >> out",
+    "build":"greet $npm_package_version",
+    "postbuild":"echo post >> out",
+    "fail":"exit 3"}}`
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(pj), 0o644)
+
+  if err := runProjectScript(context.Background(), proj, "build", []string{"with args"}, stdIO()); err != nil { t.Fatalf("run build: %v", err) }
+  b, _ := os.ReadFile(filepath.Join(proj, "out"))
+  if want := "pre\nhello 1.2.3 with args\npost\n"; string(b) != want { t.Fatalf("got %q, want %q", b, want) }
+
+  err := runProjectScript(context.Background(), proj, "fail", nil, stdIO())
+  if err == nil || exitCode(err) != 3 { t.Fatalf("want exit code 3, got %v", err) }
+  if err := runProjectScript(context.Background(), proj, "nope", nil, stdIO()); err == nil { t.Fatalf("missing script should fail") }
+}
+
+func TestInstallRunsProjectLifecycle(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("scripts use sh") }
+  newTestRegistry(t, testPkg{Name: "a", Version: "1.0.0"})
+  proj := t.TempDir()
+  pj := `{"dependencies":{"a":"1.0.0"},"scripts":{"postinstall":"test -d node_modules/a && echo postinstall >> out","prepare":"echo prepare >> out"}}`
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(pj), 0o644)
+
+  runCLI(t, "install", "--dir", proj)
+  b, _ := os.ReadFile(filepath.Join(proj, "out"))
+  if string(b) != "postinstall\nprepare\n" { t.Fatalf("unexpected lifecycle output %q", b) }
+
+  runCLI(t, "install", "--ignore-scripts", "--dir", proj)
+  if b2, _ := os.ReadFile(filepath.Join(proj, "out")); string(b2) != string(b) { t.Fatalf("--ignore-scripts should skip project scripts") }
+}
+
+func TestRunPassesArgsAfterDash(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("scripts use sh") }
+  proj := t.TempDir()
+  pj := `{"name":"app","scripts":{"t":"sh ./args.sh"}}`
+  _ = os.WriteFile(filepath.Join(proj, "package.json"), []byte(pj), 0o644)
+  _ = os.WriteFile(filepath.Join(proj, "args.sh"), []byte("printf '%s\\n' \"$@\" > out\n"), 0o644)
+
+  runCLI(t, "run", "--dir", proj, "t", "--", "--watch", "x")
+  b, _ := os.ReadFile(filepath.Join(proj, "out"))
+  if string(b) != "--watch\nx\n" { t.Fatalf("script argv %q, want --watch x", b) }
+  // only the separator is dropped, later ones reach the script
+  runCLI(t, "run", "--dir", proj, "t", "--watch", "--", "y")
+  b, _ = os.ReadFile(filepath.Join(proj, "out"))
+  if string(b) != "--watch\n--\ny\n" { t.Fatalf("script argv %q", b) }
+}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+  "fmt"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+// npm-compatible versions and ranges, following node-semver in loose mode:
+// x-ranges, "*" and "", hyphen ranges, ^ and ~, "||" sets, v/= prefixes and
+// prereleases, which only match a range that names a prerelease of the same
+// major.minor.patch.
+
+// npmVersion is a parsed semver version. Build metadata is ignored.
+type npmVersion struct {
+  Major, Minor, Patch int64
+  Pre                 []string
+}
+
+func (v *npmVersion) String() string {
+  s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+  if len(v.Pre) > 0 { s += "-" + strings.Join(v.Pre, ".") }
+  return s
+}
+
+func (v *npmVersion) sameTuple(o *npmVersion) bool {
+  return v.Major == o.Major && v.Minor == o.Minor && v.Patch == o.Patch
+}
+
+// compare returns -1, 0 or 1 as v sorts before, with or after o.
+func (v *npmVersion) compare(o *npmVersion) int {
+  for _, d := range [][2]int64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+    if d[0] != d[1] {
+      if d[0] < d[1] { return -1 }
+      return 1
+    }
+  }
+  // a prerelease sorts before its release
+  switch {
+  case len(v.Pre) == 0 && len(o.Pre) == 0:
+    return 0
+  case len(v.Pre) == 0:
+    return 1
+  case len(o.Pre) == 0:
+    return -1
+  }
+  for i := 0; i < len(v.Pre) && i < len(o.Pre); i++ {
+    if c := comparePreID(v.Pre[i], o.Pre[i]); c != 0 { return c }
+  }
+  switch {
+  case len(v.Pre) < len(o.Pre):
+    return -1
+  case len(v.Pre) > len(o.Pre):
+    return 1
+  }
+  return 0
+}
+
+// comparePreID orders prerelease identifiers: numeric ones numerically and
+// before alphanumeric ones, which compare as strings.
+func comparePreID(a, b string) int {
+  an, aErr := strconv.ParseInt(a, 10, 64)
+  bn, bErr := strconv.ParseInt(b, 10, 64)
+  switch {
+  case aErr == nil && bErr == nil:
+    if an < bn { return -1 }
+    if an > bn { return 1 }
+    return 0
+  case aErr == nil:
+    return -1
+  case bErr == nil:
+    return 1
+  }
+  return strings.Compare(a, b)
+}
+
+// partialPattern matches a loose, possibly partial version: 1, 1.2.x, v1.2.3,
+// =1.2.3-beta.1, 1.2.3beta+build.
+var partialPattern = regexp.MustCompile(`^[v=\s]*([xX*]|\d+)(?:\.([xX*]|\d+)(?:\.([xX*]|\d+)(?:-?((?:\d+|\d*[a-zA-Z-][a-zA-Z0-9-]*)(?:\.(?:\d+|\d*[a-zA-Z-][a-zA-Z0-9-]*))*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?)?)?$`)
+
+// partial is a version whose trailing parts may be wildcards (-1).
+type partial struct {
+  parts [3]int64
+  pre   []string
+}
+
+func (p partial) isX(i int) bool { return p.parts[i] < 0 }
+
+func (p partial) version() npmVersion {
+  return npmVersion{Major: p.parts[0], Minor: p.parts[1], Patch: p.parts[2], Pre: p.pre}
+}
+
+func parsePartial(s string) (partial, error) {
+  m := partialPattern.FindStringSubmatch(s)
+  if m == nil { return partial{}, fmt.Errorf("invalid version %q", s) }
+  var p partial
+  wild := false
+  for i := 0; i < 3; i++ {
+    part := m[i+1]
+    if wild || part == "" || part == "x" || part == "X" || part == "*" {
+      p.parts[i], wild = -1, true
+      continue
+    }
+    n, err := strconv.ParseInt(part, 10, 64)
+    if err != nil { return partial{}, fmt.Errorf("invalid version %q", s) }
+    p.parts[i] = n
+  }
+  if m[4] != "" { p.pre = strings.Split(m[4], ".") }
+  return p, nil
+}
+
+// parseVersion parses a full version loosely (v1.2.3, =1.2.3, 1.2.3beta).
+func parseVersion(s string) (*npmVersion, error) {
+  p, err := parsePartial(strings.TrimSpace(s))
+  if err != nil || p.isX(0) || p.isX(1) || p.isX(2) { return nil, fmt.Errorf("invalid version %q", s) }
+  v := p.version()
+  return &v, nil
+}
+
+type comparator struct {
+  op string // <, <=, >, >=, =
+  v  npmVersion
+}
+
+func (c comparator) test(v *npmVersion) bool {
+  d := v.compare(&c.v)
+  switch c.op {
+  case "<":
+    return d < 0
+  case "<=":
+    return d <= 0
+  case ">":
+    return d > 0
+  case ">=":
+    return d >= 0
+  }
+  return d == 0
+}
+
+// npmRange is a union of comparator sets; an empty set matches any release.
+type npmRange [][]comparator
+
+var (
+  hyphenPattern   = regexp.MustCompile(`^\s*(\S+)\s+-\s+(\S+)\s*$`)
+  operatorSpacing = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+  operatorPrefix  = regexp.MustCompile(`^(<=|>=|<|>|=)?(.*)$`)
+  // nothing matches <0.0.0-0
+  matchNone = []comparator{{op: "<", v: npmVersion{Pre: []string{"0"}}}}
+)
+
+// parseRange parses an npm range such as "^1.2.3", "1.x || >=2.5.0 <3",
+// "1.2 - 2" or "".
+func parseRange(s string) (npmRange, error) {
+  var r npmRange
+  for _, set := range strings.Split(s, "||") {
+    cs, err := parseComparatorSet(set)
+    if err != nil { return nil, fmt.Errorf("invalid range %q: %w", s, err) }
+    r = append(r, cs)
+  }
+  return r, nil
+}
+
+func parseComparatorSet(set string) ([]comparator, error) {
+  if m := hyphenPattern.FindStringSubmatch(set); m != nil {
+    return hyphenRange(m[1], m[2])
+  }
+  out := []comparator{}
+  for _, tok := range strings.Fields(operatorSpacing.ReplaceAllString(set, "$1")) {
+    var cs []comparator
+    var err error
+    switch {
+    case strings.HasPrefix(tok, "^"):
+      cs, err = caretRange(tok[1:])
+    case strings.HasPrefix(tok, "~>"):
+      cs, err = tildeRange(tok[2:])
+    case strings.HasPrefix(tok, "~"):
+      cs, err = tildeRange(tok[1:])
+    default:
+      m := operatorPrefix.FindStringSubmatch(tok)
+      cs, err = xRange(m[1], m[2])
+    }
+    if err != nil { return nil, err }
+    out = append(out, cs...)
+  }
+  return out, nil
+}
+
+func bound(op string, major, minor, patch int64, pre ...string) comparator {
+  return comparator{op: op, v: npmVersion{Major: major, Minor: minor, Patch: patch, Pre: pre}}
+}
+
+// caretRange allows changes that do not modify the left-most non-zero part.
+func caretRange(s string) ([]comparator, error) {
+  p, err := parsePartial(s)
+  if err != nil { return nil, err }
+  M, m, pt := p.parts[0], p.parts[1], p.parts[2]
+  switch {
+  case p.isX(0):
+    return nil, nil
+  case p.isX(1):
+    return []comparator{bound(">=", M, 0, 0), bound("<", M+1, 0, 0, "0")}, nil
+  case p.isX(2):
+    if M == 0 { return []comparator{bound(">=", M, m, 0), bound("<", M, m+1, 0, "0")}, nil }
+    return []comparator{bound(">=", M, m, 0), bound("<", M+1, 0, 0, "0")}, nil
+  }
+  lower := comparator{op: ">=", v: p.version()}
+  switch {
+  case M == 0 && m == 0:
+    return []comparator{lower, bound("<", M, m, pt+1, "0")}, nil
+  case M == 0:
+    return []comparator{lower, bound("<", M, m+1, 0, "0")}, nil
+  }
+  return []comparator{lower, bound("<", M+1, 0, 0, "0")}, nil
+}
+
+// tildeRange allows patch changes, or minor ones when no minor is given.
+func tildeRange(s string) ([]comparator, error) {
+  p, err := parsePartial(s)
+  if err != nil { return nil, err }
+  M, m := p.parts[0], p.parts[1]
+  switch {
+  case p.isX(0):
+    return nil, nil
+  case p.isX(1):
+    return []comparator{bound(">=", M, 0, 0), bound("<", M+1, 0, 0, "0")}, nil
+  case p.isX(2):
+    return []comparator{bound(">=", M, m, 0), bound("<", M, m+1, 0, "0")}, nil
+  }
+  return []comparator{{op: ">=", v: p.version()}, bound("<", M, m+1, 0, "0")}, nil
+}
+
+// xRange expands a comparator whose version may be partial or wildcarded.
+func xRange(op, s string) ([]comparator, error) {
+  p, err := parsePartial(s)
+  if err != nil { return nil, err }
+  M, m, pt := p.parts[0], p.parts[1], p.parts[2]
+  xM, xm, xp := p.isX(0), p.isX(1), p.isX(2)
+  if op == "=" && xp { op = "" }
+  switch {
+  case xM:
+    if op == ">" || op == "<" { return matchNone, nil }
+    return nil, nil
+  case op != "" && xp:
+    if xm { m = 0 }
+    pt = 0
+    switch op {
+    case ">":
+      op = ">="
+      if xm {
+        M, m = M+1, 0
+      } else {
+        m++
+      }
+    case "<=":
+      op = "<"
+      if xm { M++ } else { m++ }
+    }
+    if op == "<" { return []comparator{bound(op, M, m, pt, "0")}, nil }
+    return []comparator{bound(op, M, m, pt)}, nil
+  case xm:
+    return []comparator{bound(">=", M, 0, 0), bound("<", M+1, 0, 0, "0")}, nil
+  case xp:
+    return []comparator{bound(">=", M, m, 0), bound("<", M, m+1, 0, "0")}, nil
+  }
+  if op == "" { op = "=" }
+  return []comparator{{op: op, v: p.version()}}, nil
+}
+
+// hyphenRange expands "from - to"; partial ends widen the range.
+func hyphenRange(from, to string) ([]comparator, error) {
+  f, err := parsePartial(from)
+  if err != nil { return nil, err }
+  t, err := parsePartial(to)
+  if err != nil { return nil, err }
+  var out []comparator
+  switch {
+  case f.isX(0):
+  case f.isX(1):
+    out = append(out, bound(">=", f.parts[0], 0, 0))
+  case f.isX(2):
+    out = append(out, bound(">=", f.parts[0], f.parts[1], 0))
+  default:
+    out = append(out, comparator{op: ">=", v: f.version()})
+  }
+  switch {
+  case t.isX(0):
+  case t.isX(1):
+    out = append(out, bound("<", t.parts[0]+1, 0, 0, "0"))
+  case t.isX(2):
+    out = append(out, bound("<", t.parts[0], t.parts[1]+1, 0, "0"))
+  default:
+    out = append(out, comparator{op: "<=", v: t.version()})
+  }
+  return out, nil
+}
+
+// test reports whether v satisfies r.
+func (r npmRange) test(v *npmVersion) bool {
+  for _, set := range r {
+    if testSet(set, v) { return true }
+  }
+  return false
+}
+
+func testSet(set []comparator, v *npmVersion) bool {
+  for _, c := range set {
+    if !c.test(v) { return false }
+  }
+  if len(v.Pre) == 0 { return true }
+  // a prerelease needs a comparator with a prerelease of the same tuple
+  for _, c := range set {
+    if len(c.v.Pre) > 0 && c.v.sameTuple(v) { return true }
+  }
+  return false
+}
+
+// maxSatisfying returns the highest of versions in r, or "".
+func maxSatisfying(versions []string, r npmRange) string {
+  var best *npmVersion
+  chosen := ""
+  for _, s := range versions {
+    v, err := parseVersion(s)
+    if err != nil || !r.test(v) { continue }
+    if best == nil || v.compare(best) > 0 { best, chosen = v, s }
+  }
+  return chosen
+}
+
+// validRange reports whether spec parses as an npm range.
+func validRange(spec string) bool {
+  _, err := parseRange(spec)
+  return err == nil
+}
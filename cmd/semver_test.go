@@ -0,0 +1,264 @@
+package cmd
+
+import (
+  "context"
+  "testing"
+)
+
+// Cases from node-semver's test/fixtures/range-include.js and
+// range-exclude.js (loose mode, without includePrerelease).
+var rangeInclude = [][2]string{
+  {"1.0.0 - 2.0.0", "1.2.3"},
+  {"^1.2.3+build", "1.2.3"},
+  {"^1.2.3+build", "1.3.0"},
+  {"1.2.3-pre+asdf - 2.4.3-pre+asdf", "1.2.3"},
+  {"1.2.3pre+asdf - 2.4.3-pre+asdf", "1.2.3"},
+  {"1.2.3-pre+asdf - 2.4.3pre+asdf", "1.2.3"},
+  {"1.2.3pre+asdf - 2.4.3pre+asdf", "1.2.3"},
+  {"1.2.3-pre+asdf - 2.4.3-pre+asdf", "1.2.3-pre.2"},
+  {"1.2.3-pre+asdf - 2.4.3-pre+asdf", "2.4.3-alpha"},
+  {"1.2.3+asdf - 2.4.3+asdf", "1.2.3"},
+  {"1.0.0", "1.0.0"},
+  {">=*", "0.2.4"},
+  {"", "1.0.0"},
+  {"*", "1.2.3"},
+  {"*", "v1.2.3"},
+  {">=1.0.0", "1.0.0"},
+  {">=1.0.0", "1.0.1"},
+  {">=1.0.0", "1.1.0"},
+  {">1.0.0", "1.0.1"},
+  {">1.0.0", "1.1.0"},
+  {"<=2.0.0", "2.0.0"},
+  {"<=2.0.0", "1.9999.9999"},
+  {"<=2.0.0", "0.2.9"},
+  {"<2.0.0", "1.9999.9999"},
+  {"<2.0.0", "0.2.9"},
+  {">= 1.0.0", "1.0.0"},
+  {">=  1.0.0", "1.0.1"},
+  {">=   1.0.0", "1.1.0"},
+  {"> 1.0.0", "1.0.1"},
+  {">  1.0.0", "1.1.0"},
+  {"<=   2.0.0", "2.0.0"},
+  {"<= 2.0.0", "1.9999.9999"},
+  {"<=  2.0.0", "0.2.9"},
+  {"<    2.0.0", "1.9999.9999"},
+  {"<\t2.0.0", "0.2.9"},
+  {">=0.1.97", "v0.1.97"},
+  {">=0.1.97", "0.1.97"},
+  {"0.1.20 || 1.2.4", "1.2.4"},
+  {">=0.2.3 || <0.0.1", "0.0.0"},
+  {">=0.2.3 || <0.0.1", "0.2.3"},
+  {">=0.2.3 || <0.0.1", "0.2.4"},
+  {"||", "1.3.4"},
+  {"2.x.x", "2.1.3"},
+  {"1.2.x", "1.2.3"},
+  {"1.2.x || 2.x", "2.1.3"},
+  {"1.2.x || 2.x", "1.2.3"},
+  {"x", "1.2.3"},
+  {"2.*.*", "2.1.3"},
+  {"1.2.*", "1.2.3"},
+  {"1.2.* || 2.*", "2.1.3"},
+  {"1.2.* || 2.*", "1.2.3"},
+  {"*", "1.2.3"},
+  {"2", "2.1.2"},
+  {"2.3", "2.3.1"},
+  {"~0.0.1", "0.0.1"},
+  {"~0.0.1", "0.0.2"},
+  {"~x", "0.0.9"},
+  {"~2", "2.0.9"},
+  {"~2.4", "2.4.0"},
+  {"~2.4", "2.4.5"},
+  {"~>3.2.1", "3.2.2"},
+  {"~1", "1.2.3"},
+  {"~>1", "1.2.3"},
+  {"~> 1", "1.2.3"},
+  {"~1.0", "1.0.2"},
+  {"~ 1.0", "1.0.2"},
+  {"~ 1.0.3", "1.0.12"},
+  {"~ 1.0.3alpha", "1.0.12"},
+  {">=1", "1.0.0"},
+  {">= 1", "1.0.0"},
+  {"<1.2", "1.1.1"},
+  {"< 1.2", "1.1.1"},
+  {"~v0.5.4-pre", "0.5.5"},
+  {"~v0.5.4-pre", "0.5.4"},
+  {"=0.7.x", "0.7.2"},
+  {"<=0.7.x", "0.7.2"},
+  {">=0.7.x", "0.7.2"},
+  {"<=0.7.x", "0.6.2"},
+  {"~1.2.1 >=1.2.3", "1.2.3"},
+  {"~1.2.1 =1.2.3", "1.2.3"},
+  {"~1.2.1 1.2.3", "1.2.3"},
+  {"~1.2.1 >=1.2.3 1.2.3", "1.2.3"},
+  {"~1.2.1 1.2.3 >=1.2.3", "1.2.3"},
+  {">=1.2.1 1.2.3", "1.2.3"},
+  {"1.2.3 >=1.2.1", "1.2.3"},
+  {">=1.2.3 >=1.2.1", "1.2.3"},
+  {">=1.2.1 >=1.2.3", "1.2.3"},
+  {">=1.2", "1.2.8"},
+  {"^1.2.3", "1.8.1"},
+  {"^0.1.2", "0.1.2"},
+  {"^0.1", "0.1.2"},
+  {"^0.0.1", "0.0.1"},
+  {"^1.2", "1.4.2"},
+  {"^1.2 ^1", "1.4.2"},
+  {"^1.2.3-alpha", "1.2.3-pre"},
+  {"^1.2.0-alpha", "1.2.0-pre"},
+  {"^0.0.1-alpha", "0.0.1-beta"},
+  {"^0.0.1-alpha", "0.0.1"},
+  {"^0.1.1-alpha", "0.1.1-beta"},
+  {"^x", "1.2.3"},
+  {"x - 1.0.0", "0.9.7"},
+  {"x - 1.x", "0.9.7"},
+  {"1.0.0 - x", "1.9.7"},
+  {"1.x - x", "1.9.7"},
+  {"<=7.x", "7.9.9"},
+  {"2.x", "2.0.0"},
+  {">=0.0.0-0", "0.0.0"},
+  {"^0.x", "0.9.2"},
+  {"^0.0.x", "0.0.5"},
+}
+
+var rangeExclude = [][2]string{
+  {"1.0.0 - 2.0.0", "2.2.3"},
+  {"1.2.3+asdf - 2.4.3+asdf", "1.2.3-pre.2"},
+  {"1.2.3+asdf - 2.4.3+asdf", "2.4.3-alpha"},
+  {"^1.2.3+build", "2.0.0"},
+  {"^1.2.3+build", "1.2.0"},
+  {"^1.2.3", "1.2.3-pre"},
+  {"^1.2", "1.2.0-pre"},
+  {">1.2", "1.3.0-beta"},
+  {"<=1.2.3", "1.2.3-beta"},
+  {"^1.2.3", "1.2.3-beta"},
+  {"=0.7.x", "0.7.0-asdf"},
+  {">=0.7.x", "0.7.0-asdf"},
+  {"<=0.7.x", "0.7.0-asdf"},
+  {"1", "1.0.0beta"},
+  {"<1", "1.0.0beta"},
+  {"< 1", "1.0.0beta"},
+  {"1.0.0", "1.0.1"},
+  {">=1.0.0", "0.0.0"},
+  {">=1.0.0", "0.0.1"},
+  {">=1.0.0", "0.1.0"},
+  {">1.0.0", "0.0.1"},
+  {">1.0.0", "0.1.0"},
+  {"<=2.0.0", "3.0.0"},
+  {"<=2.0.0", "2.9999.9999"},
+  {"<=2.0.0", "2.2.9"},
+  {"<2.0.0", "2.9999.9999"},
+  {"<2.0.0", "2.2.9"},
+  {">=0.1.97", "v0.1.93"},
+  {">=0.1.97", "0.1.93"},
+  {"0.1.20 || 1.2.4", "1.2.3"},
+  {">=0.2.3 || <0.0.1", "0.0.3"},
+  {">=0.2.3 || <0.0.1", "0.2.2"},
+  {"2.x.x", "1.1.3"},
+  {"2.x.x", "3.1.3"},
+  {"1.2.x", "1.3.3"},
+  {"1.2.x || 2.x", "3.1.3"},
+  {"1.2.x || 2.x", "1.1.3"},
+  {"2.*.*", "1.1.3"},
+  {"2.*.*", "3.1.3"},
+  {"1.2.*", "1.3.3"},
+  {"1.2.* || 2.*", "3.1.3"},
+  {"1.2.* || 2.*", "1.1.3"},
+  {"2", "1.1.2"},
+  {"2.3", "2.4.1"},
+  {"~0.0.1", "0.1.0-alpha"},
+  {"~0.0.1", "0.1.0"},
+  {"~2.4", "2.5.0"},
+  {"~2.4", "2.3.9"},
+  {"~>3.2.1", "3.3.2"},
+  {"~>3.2.1", "3.2.0"},
+  {"~1", "0.2.3"},
+  {"~>1", "2.2.3"},
+  {"~1.0", "1.1.0"},
+  {"<1", "1.0.0"},
+  {">=1.2", "1.1.1"},
+  {"1", "2.0.0beta"},
+  {"~v0.5.4-beta", "0.5.4-alpha"},
+  {"=0.7.x", "0.8.2"},
+  {">=0.7.x", "0.6.2"},
+  {"<0.7.x", "0.7.2"},
+  {"<1.2.3", "1.2.3-beta"},
+  {"=1.2.3", "1.2.3-beta"},
+  {">1.2", "1.2.8"},
+  {"^0.0.1", "0.0.2-alpha"},
+  {"^0.0.1", "0.0.2"},
+  {"^1.2.3", "2.0.0-alpha"},
+  {"^1.2.3", "1.2.2"},
+  {"^1.2", "1.1.9"},
+  {"*", "v1.2.3-foo"},
+  {"*", "1.0.0-rc1"},
+  {"2.x", "3.0.0-pre.0"},
+  {"^1.0.0", "1.0.0-rc1"},
+  {"^1.0.0", "2.0.0-rc1"},
+  {"^1.2.3-rc2", "2.0.0"},
+  {"^1.0.0", "2.0.0-rc1"},
+  {"1 - 2", "3.0.0-pre"},
+  {"1 - 2", "2.0.0-pre"},
+  {"1 - 2", "1.0.0-pre"},
+  {"1.0 - 2", "1.0.0-pre"},
+  {"1.1.x", "1.0.0-a"},
+  {"1.1.x", "1.1.0-a"},
+  {"1.1.x", "1.2.0-a"},
+  {"1.x", "1.0.0-a"},
+  {"1.x", "1.1.0-a"},
+  {"1.x", "1.2.0-a"},
+  {">=1.0.0 <1.1.0", "1.1.0"},
+  {">=1.0.0 <1.1.0", "1.1.0-pre"},
+  {">=1.0.0 <1.1.0-pre", "1.1.0-pre"},
+  {">*", "1.0.0"},
+  {"<*", "1.0.0"},
+  {"^0.x", "1.0.0"},
+}
+
+func TestRangeFixtures(t *testing.T) {
+  check := func(rng, ver string, want bool) {
+    r, err := parseRange(rng)
+    if err != nil { t.Errorf("parseRange(%q): %v", rng, err); return }
+    v, err := parseVersion(ver)
+    if err != nil { t.Errorf("parseVersion(%q): %v", ver, err); return }
+    if got := r.test(v); got != want { t.Errorf("%q satisfies %q: got %v, want %v", ver, rng, got, want) }
+  }
+  for _, c := range rangeInclude { check(c[0], c[1], true) }
+  for _, c := range rangeExclude { check(c[0], c[1], false) }
+  for _, bad := range []string{">=1.0.0 <a", "1.2.3.4", "^latest", "~>"} {
+    if validRange(bad) { t.Errorf("%q should be invalid", bad) }
+  }
+}
+
+func TestVersionOrder(t *testing.T) {
+  // ascending, from node-semver's comparison fixtures
+  order := []string{"0.0.0-foo", "0.0.0", "0.0.1", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "v1.2.3", "1.2.4", "1.10.0", "2.0.0"}
+  for i := 1; i < len(order); i++ {
+    a, _ := parseVersion(order[i-1])
+    b, _ := parseVersion(order[i])
+    if a == nil || b == nil || a.compare(b) >= 0 { t.Errorf("%s should sort before %s", order[i-1], order[i]) }
+  }
+  if got := maxSatisfying([]string{"1.2.3", "v1.2.4", "1.3.0-beta", "2.0.0"}, mustRange(t, "^1.2")); got != "v1.2.4" { t.Errorf("maxSatisfying = %q", got) }
+}
+
+func mustRange(t *testing.T, s string) npmRange {
+  t.Helper()
+  r, err := parseRange(s)
+  if err != nil { t.Fatal(err) }
+  return r
+}
+
+func TestResolveVersionPrefersLatest(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0"},
+    testPkg{Name: "a", Version: "1.2.0-beta.1"},
+    testPkg{Name: "a", Version: "1.3.0"},
+    testPkg{Name: "a", Version: "1.1.0"},
+  )
+  ctx := context.Background()
+  cases := map[string]string{"^1.0.0": "1.1.0", "*": "1.1.0", "": "1.1.0", ">1.1.0": "1.3.0", "~1.2.0-beta": "1.2.0-beta.1", "1.x || 2": "1.1.0"}
+  for spec, want := range cases {
+    got, _, err := resolveVersionAndMetadata(ctx, "a", spec, map[string]*RootDoc{})
+    if err != nil || got != want { t.Errorf("%q resolved to %q, %v; want %s", spec, got, err, want) }
+  }
+  got, err := nextVersionForPolicy(ctx, "a", "1.0.0", "minor", map[string]*RootDoc{})
+  if err != nil || got != "1.3.0" { t.Errorf("minor update = %q, %v", got, err) }
+}
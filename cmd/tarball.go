@@ -0,0 +1,107 @@
+package cmd
+
+import (
+  "context"
+  "crypto/sha256"
+  "crypto/sha512"
+  "encoding/base64"
+  "encoding/hex"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+)
+
+// Remote tarball dependencies: a spec that is an http(s) URL of a package
+// tarball. The node version is the URL; name, version and dependencies come
+// from the tarball's package.json. The sha512 integrity computed on the first
+// download is recorded in wlim.lock and every later download must match it.
+
+func isTarballURL(spec string) bool {
+  return strings.HasPrefix(spec, "https://") || strings.HasPrefix(spec, "http://")
+}
+
+var (
+  tarballMu        sync.Mutex // guards the maps, not the downloads
+  tarballFetch     keyedMutex // one download per url at a time
+  tarballResolved  = map[string]*PackageMetadata{} // url -> metadata, per run
+  tarballIntegrity = map[string]string{}           // url -> integrity from wlim.lock
+)
+
+// rememberTarballIntegrity records the integrities of the tarball URL
+// packages of a lockfile, so resolving them again enforces the locked
+// content even when the lockfile itself is re-resolved.
+func rememberTarballIntegrity(lf *LockFile) {
+  tarballMu.Lock()
+  defer tarballMu.Unlock()
+  for _, lp := range lf.Packages {
+    if isTarballURL(lp.Version) && lp.Integrity != "" { tarballIntegrity[lp.Version] = lp.Integrity }
+  }
+}
+
+// tarballCachePath is where the download of url is kept between runs.
+func tarballCachePath(url string) (string, error) {
+  base, err := cacheBaseDir()
+  if err != nil { return "", err }
+  sum := sha256.Sum256([]byte(url))
+  return filepath.Join(base, "tarballs", hex.EncodeToString(sum[:20])+".tgz"), nil
+}
+
+// resolveTarballURL downloads url (or reuses a cached copy matching the
+// locked integrity) and returns its metadata with dist.tarball and
+// dist.integrity set.
+func resolveTarballURL(ctx context.Context, url string) (string, *PackageMetadata, error) {
+  defer tarballFetch.lock(url)()
+  tarballMu.Lock()
+  want := tarballIntegrity[url]
+  md, ok := tarballResolved[url]
+  tarballMu.Unlock()
+  if ok && (want == "" || md.Dist.Integrity == want) {
+    copy := *md
+    return url, &copy, nil
+  }
+  path, err := tarballCachePath(url)
+  if err != nil { return "", nil, err }
+  if want == "" || verifyIntegrityFile(path, want, "") != nil {
+    if err := ensureDir(filepath.Dir(path)); err != nil { return "", nil, err }
+    logf("Downloading %s\n", url)
+    if err := downloadToFileWithRetry(ctx, url, path, 3); err != nil { return "", nil, err }
+    if want != "" {
+      if err := verifyIntegrityFile(path, want, ""); err != nil {
+        _ = os.Remove(path)
+        return "", nil, fmt.Errorf("%s: %w (wlim.lock has %s)", url, err, want)
+      }
+    }
+  }
+  integrity, err := fileIntegrity(path)
+  if err != nil { return "", nil, err }
+  md, err = tarballMetadata(path)
+  if err != nil { return "", nil, err }
+  if md.Name == "" || md.Version == "" { return "", nil, fmt.Errorf("%s: package.json needs a name and version", url) }
+  md.Dist.Tarball, md.Dist.Integrity = url, integrity
+  tarballMu.Lock()
+  tarballResolved[url] = md
+  tarballMu.Unlock()
+  copy := *md
+  return url, &copy, nil
+}
+
+// fileIntegrity returns the sha512 SRI string of a file.
+func fileIntegrity(path string) (string, error) {
+  f, err := os.Open(path)
+  if err != nil { return "", err }
+  defer f.Close()
+  h := sha512.New()
+  if _, err := io.Copy(h, f); err != nil { return "", err }
+  return "sha512-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// tarballStoreRef is the store directory of a tarball URL node ref:
+// url-<hash of the URL> plus any peer suffix.
+func tarballStoreRef(ref string) string {
+  v := refVersion(ref)
+  sum := sha256.Sum256([]byte(v))
+  return "url-" + hex.EncodeToString(sum[:20]) + ref[len(v):]
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+)
+
+func TestInstallTarballURLDependency(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  srv := newTestRegistry(t,
+    testPkg{Name: "a", Version: "1.0.0"},
+    testPkg{Name: "patched", Version: "1.2.3", Deps: map[string]string{"a": "^1.0.0"}},
+  )
+  url := srv.URL + "/-/patched-1.2.3.tgz"
+  proj := t.TempDir()
+
+  runCLI(t, "install", "--dir", proj, url)
+  store, _ := defaultStoreDir()
+  link, err := os.Readlink(filepath.Join(proj, "node_modules", "patched"))
+  if err != nil || !strings.HasPrefix(link, filepath.Join(store, "patched", "url-")) { t.Fatalf("patched -> %s, %v", link, err) }
+  if _, err := os.Lstat(filepath.Join(link, "node_modules", "a")); err != nil { t.Fatalf("dependency not linked: %v", err) }
+
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatal(err) }
+  lp, ok := lf.Packages["patched@"+url]
+  if !ok || lp.Name != "patched" || !strings.HasPrefix(lp.Integrity, "sha512-") { t.Fatalf("tarball not locked with integrity: %+v", lf.Packages) }
+  if lf.RootSpecs["patched"].Spec != url { t.Fatalf("root spec: %+v", lf.RootSpecs) }
+  if lp.PackageVersion != "1.2.3" { t.Fatalf("package.json version not locked: %+v", lp) }
+
+  // a frozen install with cold caches downloads straight into the store
+  cached, err := tarballCachePath(url)
+  if err != nil { t.Fatal(err) }
+  _ = os.Remove(cached)
+  tarballResolved = map[string]*PackageMetadata{} // as in a new run
+  _ = os.RemoveAll(filepath.Join(proj, "node_modules"))
+  _ = os.RemoveAll(store)
+  runCLI(t, "install", "--frozen-lockfile", "--dir", proj)
+  if _, err := os.Stat(filepath.Join(proj, "node_modules", "patched", "package.json")); err != nil { t.Fatalf("reinstall from lockfile: %v", err) }
+  if _, err := os.Stat(cached); !os.IsNotExist(err) { t.Fatalf("frozen install resolved the tarball again: %v", err) }
+
+  // a lockfile integrity that does not match the download is rejected
+  lp.Integrity = "sha512-" + strings.Repeat("A", 86) + "=="
+  lf.Packages["patched@"+url] = lp
+  if err := saveLockfile(proj, lf); err != nil { t.Fatal(err) }
+  lf, err = readLockfile(proj)
+  if err != nil { t.Fatal(err) }
+  nodes, _, err := nodesFromLock(context.Background(), lf, make(map[string]*RootDoc))
+  if err != nil { t.Fatal(err) }
+  if err := fetchToStore(context.Background(), nodes["patched@"+url], t.TempDir()); err == nil || !strings.Contains(err.Error(), "integrity mismatch") {
+    t.Fatalf("want integrity mismatch, got %v", err)
+  }
+}
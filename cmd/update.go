@@ -6,7 +6,6 @@ import (
   "os"
   "runtime"
   "time"
-  "strings"
 
   "github.com/spf13/cobra"
 )
@@ -22,39 +21,66 @@ var updateCmd = &cobra.Command{
     // registry override
     if r, _ := cmd.Flags().GetString("registry"); r != "" { registryOverride = r }
     if registryOverride == "" && cfg.Registry != "" { registryOverride = cfg.Registry }
+    applyPlatformFlags(cmd)
+    autoInstallPeers, _ = cmd.Flags().GetBool("auto-install-peers")
+    autoInstallPeers = autoInstallPeers || cfg.AutoInstallPeers
+    ignoreScripts, _ = cmd.Flags().GetBool("ignore-scripts")
+    if _, err := applyCutoff(cmd, cfg); err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    filter, err := sectionFilterFromFlags(cmd)
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
 
     ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
     defer cancel()
     cache := make(map[string]*RootDoc)
     policy, _ := cmd.Flags().GetString("policy")
+    tag, _ := cmd.Flags().GetString("tag")
     // parse explicit specs from args like name@spec
     specs := make(map[string]string)
     names := make([]string, 0, len(args))
     for _, a := range args {
-      name := a
-      if at := strings.LastIndex(a, "@"); at > 0 {
-        name = a[:at]
-        specs[name] = a[at+1:]
-      }
+      name, spec := splitKey(a)
+      if spec != "" { specs[name] = spec }
       names = append(names, name)
     }
-    if err := updateLockfile(ctx, projectDir, names, cache, policy, specs); err != nil {
+    if err := updateLockfile(ctx, projectDir, names, cache, policy, tag, specs); err != nil {
       fmt.Println("Error:", err)
       os.Exit(1)
     }
-    // After updating lockfile, install from it
-    nodes, roots, err := nodesFromLockfile(ctx, projectDir, cache)
+    // After updating lockfile, install the selected sections from it
+    lf, err := readLockfile(projectDir)
     if err != nil {
       fmt.Println("Error:", err)
       os.Exit(1)
     }
+    nodes, roots, err := nodesFromLock(ctx, lf, cache)
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    importers := lockImporters(lf, nodes, roots)
+    nodeVersion, strict := engineTarget(cmd, cfg)
+    if err := checkEngines(importers, importerNodes(importers, nodes, filter), nodeVersion, strict); err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
     storeDir, err := defaultStoreDir()
     if err != nil { fmt.Println("Error:", err); os.Exit(1) }
     conc, _ := cmd.Flags().GetInt("concurrency")
     if !cmd.Flags().Changed("concurrency") && cfg.Concurrency > 0 { conc = cfg.Concurrency }
     if conc <= 0 { conc = runtime.NumCPU() }
-    for _, r := range roots {
-      if err := installParallel(ctx, projectDir, storeDir, r, nodes, conc); err != nil {
+    installNodes, err := installImporters(ctx, projectDir, storeDir, importers, nodes, filter, conc)
+    if err != nil {
+      fmt.Println("Error:", err)
+      os.Exit(1)
+    }
+    if !ignoreScripts {
+      if err := buildDependencies(context.Background(), projectDir, storeDir, installNodes, cfg.OnlyBuiltDependencies); err != nil {
         fmt.Println("Error:", err)
         os.Exit(1)
       }
@@ -68,5 +94,12 @@ func init() {
   updateCmd.Flags().Int("concurrency", runtime.NumCPU(), "Parallel downloads/extract workers")
   updateCmd.Flags().String("registry", "", "Override npm registry base URL (takes precedence over WLIM_REGISTRY)")
   updateCmd.Flags().String("policy", "latest", "Update policy: latest|minor|patch")
+  updateCmd.Flags().String("tag", "", "Update to this dist-tag (e.g. next) instead of following --policy")
+  updateCmd.Flags().Bool("auto-install-peers", false, "Install missing peer dependencies automatically")
+  updateCmd.Flags().Bool("ignore-scripts", false, "Do not run lifecycle scripts, even for onlyBuiltDependencies")
+  addCutoffFlag(updateCmd)
+  addEngineFlags(updateCmd)
+  addSectionFlags(updateCmd)
+  addPlatformFlags(updateCmd)
   rootCmd.AddCommand(updateCmd)
 }
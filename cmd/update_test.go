@@ -0,0 +1,28 @@
+package cmd
+
+import (
+  "context"
+  "path/filepath"
+  "testing"
+)
+
+func TestUpdateFollowsDistTag(t *testing.T) {
+  newTestRegistry(t,
+    testPkg{Name: "react", Version: "2.0.0-rc.1", Tags: []string{"next"}},
+    testPkg{Name: "react", Version: "1.0.0"},
+    testPkg{Name: "b", Version: "1.0.0"},
+  )
+  v, _, err := resolveVersionAndMetadata(context.Background(), "react", "next", map[string]*RootDoc{})
+  if err != nil || v != "2.0.0-rc.1" { t.Fatalf("react@next resolved to %q, %v", v, err) }
+  if _, _, err := resolveVersionAndMetadata(context.Background(), "b", "canary", map[string]*RootDoc{}); err == nil { t.Fatalf("unknown tag should fail") }
+
+  proj := t.TempDir()
+  writeTestFile(t, filepath.Join(proj, "package.json"), `{"dependencies":{"react":"^1.0.0","b":"1.0.0"}}`)
+  runCLI(t, "install", "--dir", proj)
+  runCLI(t, "update", "--tag", "next", "--dir", proj)
+  lf, err := readLockfile(proj)
+  if err != nil { t.Fatal(err) }
+  for _, k := range []string{"react@2.0.0-rc.1", "b@1.0.0"} {
+    if _, ok := lf.Packages[k]; !ok { t.Fatalf("lockfile lacks %s: %v", k, lf.Packages) }
+  }
+}
@@ -29,8 +29,7 @@ func validateProject(projectDir string) error {
   if err != nil { return err }
   // roots must be linked in project
   for _, r := range lf.Roots {
-    name := r
-    if at := strings.LastIndex(r, "@"); at > 0 { name = r[:at] }
+    name, _ := splitKey(r)
     // handle scoped path
     link := filepath.Join(projectDir, "node_modules")
     if strings.HasPrefix(name, "@") {
@@ -42,10 +41,12 @@ func validateProject(projectDir string) error {
     if _, err := os.Lstat(link); err != nil { return fmt.Errorf("missing root link: %s", name) }
   }
   // store entries exist
-  for k := range lf.Packages {
-    parts := strings.SplitN(k, "@", 2)
-    if len(parts) != 2 { continue }
-    if _, err := os.Stat(filepath.Join(storeDir, parts[0], parts[1])); err != nil { return fmt.Errorf("missing store entry: %s", k) }
+  target := currentTarget()
+  for k, lp := range lf.Packages {
+    // optional and other-platform packages are legitimately absent
+    if lp.Skipped != "" || !target.supports(&PackageMetadata{OS: lp.OS, CPU: lp.CPU, Libc: lp.Libc}) { continue }
+    name, ref := splitKey(k)
+    if _, err := os.Stat(storePkgPath(storeDir, name, ref)); err != nil { return fmt.Errorf("missing store entry: %s", k) }
   }
   return nil
 }
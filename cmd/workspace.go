@@ -0,0 +1,306 @@
+package cmd
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+// workspace is a package of a monorepo, found through the root's workspace globs.
+type workspace struct {
+  Name     string
+  Version  string
+  Dir      string // slash-separated, relative to the root project
+  Manifest *projectManifest
+}
+
+// importer is a project whose dependencies an install links: the root
+// project (Dir ".") or one of its workspaces. Lists of importers start with
+// the root.
+type importer struct {
+  Dir       string
+  Name      string // package.json name, possibly empty for the root
+  Deps      []rootDep
+  Roots     []*GraphNode
+  RootSpecs map[string]LockRoot
+}
+
+// workspaceGlobs returns the workspace patterns from wlim.json, or else from
+// package.json "workspaces" (an array, or {"packages": [...]} like yarn).
+func workspaceGlobs(cfg *Config, m *projectManifest) []string {
+  if cfg != nil && len(cfg.Workspaces) > 0 { return cfg.Workspaces }
+  if m == nil || len(m.Workspaces) == 0 { return nil }
+  var globs []string
+  if err := json.Unmarshal(m.Workspaces, &globs); err == nil { return globs }
+  var obj struct {
+    Packages []string `json:"packages"`
+  }
+  if err := json.Unmarshal(m.Workspaces, &obj); err == nil { return obj.Packages }
+  return nil
+}
+
+// findWorkspaces expands globs relative to rootDir into the directories with
+// a package.json. "!" patterns exclude directories matched so far and a
+// trailing "/**" matches packages at any depth.
+func findWorkspaces(rootDir string, globs []string) ([]workspace, error) {
+  dirs := map[string]bool{}
+  for _, g := range globs {
+    exclude := strings.HasPrefix(g, "!")
+    g = strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(g, "!")), "./")
+    matches, err := matchWorkspaceGlob(rootDir, strings.TrimSuffix(g, "/"))
+    if err != nil { return nil, err }
+    for _, m := range matches {
+      if exclude { delete(dirs, m) } else { dirs[m] = true }
+    }
+  }
+  var out []workspace
+  byName := map[string]string{}
+  for dir := range dirs {
+    m, err := readProjectManifest(filepath.Join(rootDir, filepath.FromSlash(dir)))
+    if err != nil { return nil, fmt.Errorf("workspace %s: %w", dir, err) }
+    if m.Name == "" { return nil, fmt.Errorf("workspace %s: package.json has no name", dir) }
+    if other, dup := byName[m.Name]; dup { return nil, fmt.Errorf("workspaces %s and %s are both named %s", other, dir, m.Name) }
+    byName[m.Name] = dir
+    out = append(out, workspace{Name: m.Name, Version: m.Version, Dir: dir, Manifest: m})
+  }
+  sort.Slice(out, func(i, j int) bool { return out[i].Dir < out[j].Dir })
+  return out, nil
+}
+
+func matchWorkspaceGlob(rootDir, pattern string) ([]string, error) {
+  var out []string
+  add := func(p string) {
+    rel, err := filepath.Rel(rootDir, p)
+    if err != nil || rel == "." { return }
+    if _, err := os.Stat(filepath.Join(p, "package.json")); err == nil { out = append(out, filepath.ToSlash(rel)) }
+  }
+  if base, ok := strings.CutSuffix(pattern, "/**"); ok {
+    start := filepath.Join(rootDir, filepath.FromSlash(base))
+    err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+      if err != nil {
+        if os.IsNotExist(err) { return nil }
+        return err
+      }
+      if !d.IsDir() { return nil }
+      if p != start && (d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".")) { return filepath.SkipDir }
+      add(p)
+      return nil
+    })
+    return out, err
+  }
+  matches, err := filepath.Glob(filepath.Join(rootDir, filepath.FromSlash(pattern)))
+  if err != nil { return nil, fmt.Errorf("workspace pattern %q: %w", pattern, err) }
+  for _, p := range matches {
+    if fi, err := os.Stat(p); err == nil && fi.IsDir() { add(p) }
+  }
+  return out, nil
+}
+
+// loadImporters returns the root project followed by its workspaces, with
+// dependencies on sibling workspace packages turned into links and local
+// specs anchored at their importer.
+func loadImporters(projectDir string, cfg *Config, m *projectManifest) ([]*importer, error) {
+  importers := []*importer{{Dir: ".", Name: m.Name, Deps: m.rootDeps()}}
+  byName := make(map[string]workspace)
+  if globs := workspaceGlobs(cfg, m); len(globs) > 0 {
+    wss, err := findWorkspaces(projectDir, globs)
+    if err != nil { return nil, err }
+    for _, ws := range wss {
+      byName[ws.Name] = ws
+      importers = append(importers, &importer{Dir: ws.Dir, Name: ws.Name, Deps: ws.Manifest.rootDeps()})
+    }
+  }
+  for _, imp := range importers {
+    for i, d := range imp.Deps {
+      imp.Deps[i].Dir = filepath.Join(projectDir, filepath.FromSlash(imp.Dir))
+      link, err := workspaceLink(imp.Dir, d, byName)
+      if err != nil { return nil, fmt.Errorf("%s: %w", imp.Dir, err) }
+      imp.Deps[i].Link = link
+    }
+  }
+  return importers, nil
+}
+
+// workspaceLink returns the path from importerDir to the workspace package
+// d refers to, or "" when d comes from the registry. A workspace: spec must
+// name a workspace package; a plain range links when the workspace package
+// of that name satisfies it.
+func workspaceLink(importerDir string, d rootDep, byName map[string]workspace) (string, error) {
+  ws, ok := byName[d.Name]
+  rng, isWS := strings.CutPrefix(d.Spec, "workspace:")
+  if !ok {
+    if isWS { return "", fmt.Errorf("%s@%s: no workspace package named %s", d.Name, d.Spec, d.Name) }
+    return "", nil
+  }
+  if isWS {
+    if rng != "*" && rng != "^" && rng != "~" && !versionSatisfies(ws.Version, rng) {
+      return "", fmt.Errorf("%s@%s: workspace package is %s", d.Name, d.Spec, ws.Version)
+    }
+  } else if !versionSatisfies(ws.Version, d.Spec) {
+    return "", nil
+  }
+  rel, err := filepath.Rel(filepath.FromSlash(importerDir), filepath.FromSlash(ws.Dir))
+  if err != nil { return "", err }
+  return filepath.ToSlash(rel), nil
+}
+
+// mergeNodes adds nodes into all and returns roots as the nodes kept in all.
+// A package shared by several importers is optional only if it is optional
+// for all of them.
+func mergeNodes(all, nodes map[string]*GraphNode, roots []*GraphNode) []*GraphNode {
+  for k, n := range nodes {
+    if have, ok := all[k]; ok {
+      have.Optional = have.Optional && n.Optional
+      continue
+    }
+    all[k] = n
+  }
+  out := make([]*GraphNode, 0, len(roots))
+  for _, r := range roots { out = append(out, all[r.key()]) }
+  return out
+}
+
+// resolveImporters resolves the dependencies of every importer into one
+// graph, setting each importer's roots and root specs. All importers share
+// res (a fresh resolver when nil).
+func resolveImporters(ctx context.Context, importers []*importer, cache map[string]*RootDoc, res *resolver) (map[string]*GraphNode, error) {
+  if res == nil { res = newResolver(nil) }
+  all := make(map[string]*GraphNode)
+  for _, imp := range importers {
+    nodes, roots, rootSpecs, err := resolveRoots(ctx, imp.Deps, cache, res)
+    if err != nil {
+      if imp.Dir != "." { return nil, fmt.Errorf("%s: %w", imp.Dir, err) }
+      return nil, err
+    }
+    imp.Roots, imp.RootSpecs = mergeNodes(all, nodes, roots), rootSpecs
+  }
+  return all, nil
+}
+
+// lockImporters rebuilds the importers recorded in lf on top of the nodes
+// and root project roots read from it.
+func lockImporters(lf *LockFile, nodes map[string]*GraphNode, roots []*GraphNode) []*importer {
+  importers := []*importer{{Dir: ".", Roots: roots, RootSpecs: lf.RootSpecs}}
+  for _, d := range sortedImporterDirs(lf) {
+    li := lf.Importers[d]
+    imp := &importer{Dir: d, RootSpecs: li.RootSpecs}
+    for _, k := range li.Roots {
+      if n, ok := nodes[k]; ok { imp.Roots = append(imp.Roots, n) }
+    }
+    importers = append(importers, imp)
+  }
+  return importers
+}
+
+func sortedImporterDirs(lf *LockFile) []string {
+  dirs := make([]string, 0, len(lf.Importers))
+  for d := range lf.Importers { dirs = append(dirs, d) }
+  sort.Strings(dirs)
+  return dirs
+}
+
+// lockfileMatchesImporters reports whether lf was resolved from exactly the
+// dependencies of importers.
+func lockfileMatchesImporters(lf *LockFile, importers []*importer) bool {
+  if len(lf.Importers) != len(importers)-1 { return false }
+  for _, imp := range importers {
+    if imp.Dir == "." {
+      if !lockfileMatchesManifest(lf, imp.Deps) { return false }
+      continue
+    }
+    li, ok := lf.Importers[imp.Dir]
+    if !ok || !lockRootsMatch(li.Roots, li.RootSpecs, imp.Deps) { return false }
+  }
+  return true
+}
+
+// writeImportersLockfile writes one lockfile for the graph of all importers,
+// resolved with overrides.
+func writeImportersLockfile(projectDir string, importers []*importer, nodes map[string]*GraphNode, overrides map[string]string) error {
+  lf := newLockFile(importers[0].Roots, nodes, importers[0].RootSpecs)
+  lf.Overrides = overrides
+  for _, imp := range importers[1:] {
+    if lf.Importers == nil { lf.Importers = make(map[string]LockImporter) }
+    li := LockImporter{Roots: []string{}, RootSpecs: imp.RootSpecs}
+    for _, r := range imp.Roots { li.Roots = append(li.Roots, r.key()) }
+    lf.Importers[imp.Dir] = li
+  }
+  return saveLockfile(projectDir, lf)
+}
+
+// linkWorkspaceDeps symlinks the workspace packages importerDir depends on
+// into its node_modules, along with their bins. Links in sections the
+// filter leaves out are removed.
+func linkWorkspaceDeps(importerDir string, rootSpecs map[string]LockRoot, filter sectionFilter) error {
+  names := make([]string, 0, len(rootSpecs))
+  for name, rs := range rootSpecs {
+    if rs.Link != "" { names = append(names, name) }
+  }
+  sort.Strings(names)
+  for _, name := range names {
+    rs := rootSpecs[name]
+    if !filter.includes(rs.Section) {
+      _ = removeFromProject(importerDir, []string{name})
+      continue
+    }
+    target, err := filepath.Abs(filepath.Join(importerDir, filepath.FromSlash(rs.Link)))
+    if err != nil { return err }
+    vStage("link-root", name, "workspace")
+    if err := linkIntoNodeModules(filepath.Join(importerDir, "node_modules"), name, target); err != nil { return err }
+    if pj, err := readPackageJSON(target); err == nil { _ = linkBins(importerDir, target, pj) }
+  }
+  return nil
+}
+
+// selectImporters keeps the importers picked by the global -r/--filter
+// flags; without them every importer is kept.
+func selectImporters(projectDir string, importers []*importer) ([]*importer, error) {
+  if !recursiveSelected() || len(importers) < 2 { return importers, nil }
+  pkgs, err := selectedWorkspaces(projectDir)
+  if err != nil { return nil, err }
+  dirs := make(map[string]bool, len(pkgs))
+  for _, p := range pkgs { dirs[p.Dir] = true }
+  var out []*importer
+  for _, imp := range importers {
+    if dirs[imp.Dir] { out = append(out, imp) }
+  }
+  return out, nil
+}
+
+// importerNodes returns the nodes installImporters installs for the importers:
+// those reachable from the roots that filter keeps.
+func importerNodes(importers []*importer, nodes map[string]*GraphNode, filter sectionFilter) map[string]*GraphNode {
+  var all []*GraphNode
+  for _, imp := range importers {
+    kept, _ := filter.split(imp.Roots, imp.RootSpecs)
+    all = append(all, kept...)
+  }
+  return filter.nodes(all, nodes)
+}
+
+// installImporters fetches the graph of importers into the store once and
+// links every importer's roots, workspace packages and bins into its own
+// node_modules. It returns the nodes that were installed.
+func installImporters(ctx context.Context, projectDir, storeDir string, importers []*importer, nodes map[string]*GraphNode, filter sectionFilter, concurrency int) (map[string]*GraphNode, error) {
+  kept := make([][]*GraphNode, len(importers))
+  skipped := make([][]*GraphNode, len(importers))
+  var all []*GraphNode
+  for i, imp := range importers {
+    kept[i], skipped[i] = filter.split(imp.Roots, imp.RootSpecs)
+    all = append(all, kept[i]...)
+  }
+  installNodes := filter.nodes(all, nodes)
+  if err := installGraph(ctx, projectDir, storeDir, nil, installNodes, concurrency); err != nil { return nil, err }
+  for i, imp := range importers {
+    dir := filepath.Join(projectDir, filepath.FromSlash(imp.Dir))
+    if err := linkRoots(dir, storeDir, kept[i]); err != nil { return nil, err }
+    if err := linkWorkspaceDeps(dir, imp.RootSpecs, filter); err != nil { return nil, err }
+    if err := unlinkRoots(dir, skipped[i]); err != nil { fmt.Println("Warning:", err) }
+  }
+  return installNodes, nil
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+  "os"
+  "path/filepath"
+  "reflect"
+  "runtime"
+  "testing"
+)
+
+func writeTestFile(t *testing.T, path, body string) {
+  t.Helper()
+  if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { t.Fatal(err) }
+  if err := os.WriteFile(path, []byte(body), 0o644); err != nil { t.Fatal(err) }
+}
+
+func TestFindWorkspaces(t *testing.T) {
+  root := t.TempDir()
+  writeTestFile(t, filepath.Join(root, "packages", "a", "package.json"), `{"name":"a","version":"1.0.0"}`)
+  writeTestFile(t, filepath.Join(root, "packages", "b", "package.json"), `{"name":"b","version":"1.0.0"}`)
+  writeTestFile(t, filepath.Join(root, "packages", "notes", "README"), "no package.json")
+  writeTestFile(t, filepath.Join(root, "apps", "web", "site", "package.json"), `{"name":"site"}`)
+  writeTestFile(t, filepath.Join(root, "apps", "web", "node_modules", "x", "package.json"), `{"name":"x"}`)
+
+  wss, err := findWorkspaces(root, []string{"packages/*", "!packages/b", "./apps/**"})
+  if err != nil { t.Fatal(err) }
+  var dirs []string
+  for _, ws := range wss { dirs = append(dirs, ws.Dir) }
+  if want := []string{"apps/web/site", "packages/a"}; !reflect.DeepEqual(dirs, want) { t.Fatalf("dirs = %v, want %v", dirs, want) }
+
+  m := &projectManifest{Workspaces: []byte(`{"packages":["packages/*"]}`)}
+  if g := workspaceGlobs(&Config{}, m); !reflect.DeepEqual(g, []string{"packages/*"}) { t.Fatalf("globs = %v", g) }
+  if g := workspaceGlobs(&Config{Workspaces: []string{"libs/*"}}, m); !reflect.DeepEqual(g, []string{"libs/*"}) { t.Fatalf("wlim.json should win: %v", g) }
+}
+
+func TestInstallWorkspaces(t *testing.T) {
+  if runtime.GOOS == "windows" { t.Skip("symlink behavior differs on Windows") }
+  newTestRegistry(t,
+    testPkg{Name: "x", Version: "1.0.0"},
+    testPkg{Name: "y", Version: "2.0.0", Extra: map[string]any{"bin": "bin/y"}, Files: map[string]string{"bin/y": "#!/bin/sh\n"}},
+  )
+  root := t.TempDir()
+  writeTestFile(t, filepath.Join(root, "package.json"), `{"name":"mono","private":true,"workspaces":["packages/*"]}`)
+  writeTestFile(t, filepath.Join(root, "packages", "app", "package.json"), `{"name":"app","version":"1.0.0","dependencies":{"lib":"workspace:*","x":"^1.0.0"}}`)
+  writeTestFile(t, filepath.Join(root, "packages", "lib", "package.json"), `{"name":"lib","version":"0.3.0","bin":{"lib-cli":"cli.js"},"dependencies":{"y":"^2.0.0"}}`)
+  writeTestFile(t, filepath.Join(root, "packages", "lib", "cli.js"), "")
+
+  runCLI(t, "install", "--dir", root)
+  app := filepath.Join(root, "packages", "app")
+  target, err := filepath.EvalSymlinks(filepath.Join(app, "node_modules", "lib"))
+  if err != nil { t.Fatalf("lib not linked into app: %v", err) }
+  if want, _ := filepath.EvalSymlinks(filepath.Join(root, "packages", "lib")); target != want { t.Fatalf("lib links to %s, want %s", target, want) }
+  for _, p := range []string{
+    filepath.Join(app, "node_modules", "x"),
+    filepath.Join(app, "node_modules", ".bin", "lib-cli"),
+    filepath.Join(root, "packages", "lib", "node_modules", "y"),
+    filepath.Join(root, "packages", "lib", "node_modules", ".bin", "y"),
+  } {
+    if _, err := os.Lstat(p); err != nil { t.Fatalf("missing %s: %v", p, err) }
+  }
+  if _, err := os.Lstat(filepath.Join(root, "packages", "lib", "wlim.lock")); !os.IsNotExist(err) { t.Fatalf("workspaces should not get their own lockfile") }
+
+  lf, err := readLockfile(root)
+  if err != nil { t.Fatal(err) }
+  if lf.Importers["packages/app"].RootSpecs["lib"].Link != "../lib" { t.Fatalf("unexpected importer: %+v", lf.Importers["packages/app"]) }
+  if !reflect.DeepEqual(lf.Importers["packages/lib"].Roots, []string{"y@2.0.0"}) { t.Fatalf("unexpected importer: %+v", lf.Importers["packages/lib"]) }
+  if _, ok := lf.Packages["x@1.0.0"]; !ok { t.Fatalf("x missing from lockfile: %+v", lf.Packages) }
+
+  // the lockfile matches every workspace and is reused as is
+  m, _ := readProjectManifest(root)
+  importers, err := loadImporters(root, &Config{}, m)
+  if err != nil { t.Fatal(err) }
+  if !lockfileMatchesImporters(lf, importers) { t.Fatalf("lockfile should match the workspaces") }
+  writeTestFile(t, filepath.Join(root, "packages", "lib", "package.json"), `{"name":"lib","version":"0.3.0","dependencies":{"y":"^2.0.0","x":"1.0.0"}}`)
+  importers, _ = loadImporters(root, &Config{}, m)
+  if lockfileMatchesImporters(lf, importers) { t.Fatalf("a changed workspace should invalidate the lockfile") }
+}
@@ -3,11 +3,8 @@ module github.com/wicklim/wlim
 go 1.23.1
 
 require (
-	github.com/Masterminds/semver/v3 v3.2.1
 	github.com/spf13/cobra v1.8.1
+	github.com/spf13/pflag v1.0.5
 )
 
-require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect